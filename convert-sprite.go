@@ -0,0 +1,261 @@
+// convert-sprite converts a sprite sheet or animation into a low bit depth
+// image for small displays.
+//
+//	go run convert-sprite.go -bits 1 -dither bayer4 animation/2bit-sprite/sheet.png sheet-1bit.bmp
+//	go run convert-sprite.go -colors "#0f380f,#306230,#8bac0f,#9bbc0f" animation/2bit-sprite/run.gif run.h
+//
+// The output format is determined by the extension: .bmp and .png write packed
+// images, .bin writes raw packed bytes and .h writes a C array.
+// Animations are written as a vertical strip of frames.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	_ "image/jpeg"
+
+	_ "golang.org/x/image/bmp"
+	"golang.org/x/image/draw"
+
+	"github.com/egonelbre/gophers/internal/atomicfile"
+	"github.com/egonelbre/gophers/palette"
+	"github.com/egonelbre/gophers/sprite"
+)
+
+var (
+	bits        = flag.Int("bits", 1, "bits per pixel: 1, 2 or 4")
+	colors      = flag.String("colors", "", "custom palette, darkest first, e.g. #000,#555,#aaa,#fff")
+	palettePath = flag.String("palette", "", "palette file: .ase, .aseprite, .gpl, .pal or an image")
+	dither      = flag.String("dither", "none", "dithering: none, floyd-steinberg, bayer2, bayer4 or bayer8")
+	background  = flag.String("background", "#fff", "color behind transparent pixels")
+	vertical    = flag.Bool("vertical", false, "pack raw output in vertical pages, as used by SSD1306 displays")
+)
+
+// outputFormats are the supported output extensions.
+var outputFormats = map[string]bool{".bmp": true, ".png": true, ".bin": true, ".h": true}
+
+// Grayscale returns 2^bits evenly spaced gray levels, black first.
+func Grayscale(bits int) color.Palette {
+	n := 1 << uint(bits)
+	p := make(color.Palette, n)
+	for i := range p {
+		v := uint8(i * 255 / (n - 1))
+		p[i] = color.NRGBA{v, v, v, 0xff}
+	}
+	return p
+}
+
+// LoadFrames loads an image, animations are coalesced into full frames.
+func LoadFrames(path string) ([]image.Image, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	_, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if format != "gif" {
+		m, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return []image.Image{m}, nil
+	}
+
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	canvas := image.NewNRGBA(bounds)
+	frames := []image.Image{}
+	for i, m := range g.Image {
+		var previous *image.NRGBA
+		disposal := byte(0)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewNRGBA(bounds)
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, m.Bounds(), m, m.Bounds().Min, draw.Over)
+
+		frame := image.NewNRGBA(bounds)
+		copy(frame.Pix, canvas.Pix)
+		frames = append(frames, frame)
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, m.Bounds(), image.Transparent, image.ZP, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return frames, nil
+}
+
+// Strip places frames below each other on top of bg.
+func Strip(frames []image.Image, bg color.Color) image.Image {
+	size := image.Point{}
+	for _, m := range frames {
+		if m.Bounds().Dx() > size.X {
+			size.X = m.Bounds().Dx()
+		}
+		size.Y += m.Bounds().Dy()
+	}
+
+	strip := image.NewNRGBA(image.Rectangle{image.ZP, size})
+	draw.Draw(strip, strip.Bounds(), &image.Uniform{bg}, image.ZP, draw.Src)
+
+	y := 0
+	for _, m := range frames {
+		r := image.Rect(0, y, m.Bounds().Dx(), y+m.Bounds().Dy())
+		draw.Draw(strip, r, m, m.Bounds().Min, draw.Over)
+		y += m.Bounds().Dy()
+	}
+	return strip
+}
+
+func parseColors(s string) (color.Palette, error) {
+	p := color.Palette{}
+	for _, hex := range strings.Split(s, ",") {
+		c, err := palette.ParseColor(strings.TrimSpace(hex))
+		if err != nil {
+			return nil, err
+		}
+		p = append(p, c)
+	}
+	return p, nil
+}
+
+var rxIdentifier = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
+
+// WriteHeader writes packed data as a C array.
+func WriteHeader(w io.Writer, name string, m *image.Paletted, bits int, layout sprite.Layout) error {
+	name = rxIdentifier.ReplaceAllString(name, "_")
+	// C identifiers can't start with a digit
+	if name == "" || !isLetter(name[0]) {
+		name = "_" + name
+	}
+	data := sprite.Pack(m, bits, layout)
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "// %dx%d, %d bits per pixel\n", m.Bounds().Dx(), m.Bounds().Dy(), bits)
+	fmt.Fprintf(bw, "#define %s_WIDTH %d\n", strings.ToUpper(name), m.Bounds().Dx())
+	fmt.Fprintf(bw, "#define %s_HEIGHT %d\n\n", strings.ToUpper(name), m.Bounds().Dy())
+	fmt.Fprintf(bw, "const unsigned char %s[%d] = {", name, len(data))
+	for i, v := range data {
+		if i%16 == 0 {
+			fmt.Fprintf(bw, "\n\t")
+		} else {
+			fmt.Fprintf(bw, " ")
+		}
+		fmt.Fprintf(bw, "0x%02x,", v)
+	}
+	fmt.Fprintf(bw, "\n};\n")
+	return bw.Flush()
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func main() {
+	flag.Parse()
+
+	if flag.Arg(0) == "" || flag.Arg(1) == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	output := flag.Arg(1)
+	ext := strings.ToLower(filepath.Ext(output))
+	if !outputFormats[ext] {
+		check(fmt.Errorf("unknown output format %q", filepath.Ext(output)))
+	}
+
+	var pal color.Palette
+	var err error
+	switch {
+	case *colors != "":
+		pal, err = parseColors(*colors)
+	case *palettePath != "":
+		pal, err = palette.Load(*palettePath)
+	default:
+		if *bits != 1 && *bits != 2 && *bits != 4 {
+			err = fmt.Errorf("unsupported bits %d", *bits)
+		}
+		pal = Grayscale(*bits)
+	}
+	check(err)
+
+	// the smallest depth, which fits the palette
+	depth := 1
+	for len(pal) > 1<<uint(depth) {
+		depth *= 2
+	}
+	if depth > 8 {
+		check(fmt.Errorf("palette has %d colors, at most 256 supported", len(pal)))
+	}
+
+	mode, ok := palette.ParseDither(*dither)
+	if !ok {
+		check(fmt.Errorf("unknown dither %q", *dither))
+	}
+
+	bg, err := palette.ParseColor(*background)
+	check(err)
+
+	frames, err := LoadFrames(flag.Arg(0))
+	check(err)
+
+	target := palette.Remap(Strip(frames, bg), pal, mode)
+
+	layout := sprite.Rows
+	if *vertical {
+		layout = sprite.Pages
+	}
+
+	var buf bytes.Buffer
+	switch ext {
+	case ".bmp":
+		err = sprite.EncodeBMP(&buf, target, depth)
+	case ".png":
+		err = png.Encode(&buf, target)
+	case ".bin":
+		_, err = buf.Write(sprite.Pack(target, depth, layout))
+	case ".h":
+		name := filepath.Base(output)
+		name = name[:len(name)-len(filepath.Ext(name))]
+		err = WriteHeader(&buf, name, target, depth, layout)
+	}
+	check(err)
+	check(atomicfile.WriteFile(output, buf.Bytes()))
+}
+
+func check(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed: %v\n", err)
+		os.Exit(1)
+	}
+}
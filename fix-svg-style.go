@@ -1,215 +1,343 @@
 // fix-svg-style fixes Inkscape palette to be compatible with Affinity Designer
 //
+// The fixes are implemented as passes in package svg, use -list to see them.
+// Without -write or -check it only reports which files would change.
+//
+//	go run fix-svg-style.go -write
+//	go run fix-svg-style.go -check vector/fairy-tale
+//	go run fix-svg-style.go -write -passes default,-remove-dead-gradients vector/friends/docker.svg
+//	go run fix-svg-style.go -write -passes text-to-path -font GreatVibes-Regular.ttf vector/fairy-tale
+//
 
 package main
 
 import (
-	"bytes"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"regexp"
+	"strings"
+
+	"golang.org/x/image/font/sfnt"
+
+	"github.com/egonelbre/gophers/internal/atomicfile"
+	"github.com/egonelbre/gophers/svg"
+)
 
-	"golang.org/x/net/html"
-	"golang.org/x/net/html/atom"
+var (
+	passes    = flag.String("passes", "default", "comma separated passes to run, \"all\", \"default\" or -name to exclude a pass")
+	list      = flag.Bool("list", false, "list available passes")
+	verbose   = flag.Bool("v", false, "print every change")
+	write     = flag.Bool("write", false, "write changes to the files")
+	checkOnly = flag.Bool("check", false, "print a diff of the changes and exit with status 1 when any file would change")
+
+	keepLayerNames = flag.Bool("keep-layer-names", false, "keep Inkscape layer names when stripping editor data")
+	precision      = flag.Int("precision", 3, "decimals kept when rounding numbers")
+	fontFiles      = flag.String("font", "", "comma separated TTF or OTF files used by text-to-path, the first one is the fallback")
 )
 
+// fonts are the parsed -font files.
+var fonts []*sfnt.Font
+
 func main() {
-	err := filepath.Walk("vector", func(path string, info os.FileInfo, err error) error {
-		if info.IsDir() {
-			return nil
-		}
-		if filepath.Ext(path) == ".svg" {
-			return ProcessSVGFile(path)
+	flag.Parse()
+
+	if *list {
+		for _, pass := range svg.Passes {
+			mark := " "
+			if pass.Default {
+				mark = "*"
+			}
+			fmt.Printf("%s %-24s %s\n", mark, pass.Name, pass.Description)
 		}
-		return nil
-	})
-	if err != nil {
-		panic(err)
+		return
 	}
-}
 
-func ProcessSVGFile(file string) error {
-	data, err := ioutil.ReadFile(file)
-	if err != nil {
-		return err
+	if *write && *checkOnly {
+		check(fmt.Errorf("-write and -check can't be used together"))
 	}
 
-	input := bytes.NewReader(data)
-	context := &html.Node{
-		Type: html.ElementNode,
-	}
+	selected, err := svg.SelectPasses(*passes)
+	check(err)
 
-	nodes, err := html.ParseFragment(input, context)
-	if err != nil {
-		return err
+	fonts, err = LoadFonts(*fontFiles)
+	check(err)
+	if len(fonts) == 0 {
+		for _, name := range strings.Split(*passes, ",") {
+			if strings.TrimSpace(name) == svg.TextToPathPass.Name {
+				check(fmt.Errorf("text-to-path needs -font"))
+			}
+		}
+		// "all" includes text-to-path, which can't run without fonts
+		for i, pass := range selected {
+			if pass == svg.TextToPathPass {
+				selected = append(selected[:i], selected[i+1:]...)
+				break
+			}
+		}
 	}
 
-	changed := ProcessSVG(nodes)
-
-	output := bytes.NewBuffer(nil)
-	for _, node := range nodes {
-		html.Render(output, node)
+	roots := flag.Args()
+	if len(roots) == 0 {
+		roots = []string{"vector"}
 	}
 
-	outdata := output.Bytes()
-	if changed {
-		fmt.Println("Wrinting ", file)
-		err2 := ioutil.WriteFile(file, outdata, 0755)
-		if err2 != nil {
-			return err2
-		}
+	changed := 0
+	for _, root := range roots {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || filepath.Ext(path) != ".svg" {
+				return nil
+			}
+			modified, err := ProcessSVGFile(path, selected)
+			if modified {
+				changed++
+			}
+			return err
+		})
+		check(err)
 	}
 
-	return nil
+	switch {
+	case *checkOnly && changed > 0:
+		fmt.Fprintf(os.Stderr, "%d files need fixing\n", changed)
+		os.Exit(1)
+	case !*write && !*checkOnly && changed > 0:
+		fmt.Printf("%d files would change, use -write to fix them\n", changed)
+	}
 }
 
-func ProcessSVG(nodes []*html.Node) bool {
-	changed := false
+// ProcessSVGFile runs passes on file and reports whether it changed.
+func ProcessSVGFile(file string, passes []*svg.Pass) (bool, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return false, err
+	}
 
-	getElementByID := map[string]*html.Node{}
-	var process func(node *html.Node)
+	doc, err := svg.ParseString(string(data))
+	if err != nil {
+		return false, fmt.Errorf("%v: %v", file, err)
+	}
 
-	rxRemoveStyle := regexp.MustCompile(`(visibility:visible)[;$]`)
-	rxFillStyle := regexp.MustCompile(`fill:url\((#[a-zA-Z0-9\-]+)\)`)
-	rxStrokeStyle := regexp.MustCompile(`stroke:url\((#[a-zA-Z0-9\-]+)\)`)
-	rxStopColor := regexp.MustCompile(`stop-color:([^;]*)[;$]`)
+	// keep the diff on stdout usable as a patch
+	log := os.Stdout
+	if *checkOnly {
+		log = os.Stderr
+	}
 
-	resolveColorCache := map[string]string{}
-	resolveColor := func(id string) string {
-		if cached, ok := resolveColorCache[id]; ok {
-			return cached
+	for _, result := range svg.Run(doc, passes, &svg.Options{
+		KeepLayerNames: *keepLayerNames,
+		Precision:      *precision,
+		Fonts:          fonts,
+	}) {
+		if !result.Report.Changed() {
+			continue
 		}
 
-		node := getElementByID[id]
-		for {
-			xlink := GetAttributeValue(node, "href")
-			if xlink == "" {
-				break
+		fmt.Fprintf(log, "%v: %v: %d changes\n", file, result.Pass.Name, len(result.Report.Changes))
+		if *verbose {
+			for _, change := range result.Report.Changes {
+				fmt.Fprintf(log, "\t%v\n", change)
 			}
-			node = getElementByID[xlink[1:]]
 		}
+	}
 
-		stops := GetElementsByTagName(node, "stop")
-		if len(stops) == 0 {
-			return "rgba(0,0,0,0)"
-		}
-		if len(stops) >= 2 {
-			return "url(#" + id + ")"
-		}
-
-		stop := stops[0]
-		style := GetAttributeValue(stop, "style")
-		match := rxStopColor.FindStringSubmatch(style)
-
-		resolveColorCache[id] = match[1]
-		return match[1]
+	output := doc.Bytes()
+	if string(output) == string(data) {
+		return false, nil
 	}
 
-	process = func(node *html.Node) {
-		// build index
-		id := GetAttributeValue(node, "id")
-		if id != "" {
-			getElementByID[id] = node
+	switch {
+	case *checkOnly:
+		fmt.Print(UnifiedDiff(file, string(data), string(output)))
+	case *write:
+		fmt.Println("Writing", file)
+		if err := atomicfile.WriteFile(file, output); err != nil {
+			return true, err
 		}
+	}
+	return true, nil
+}
 
-		// recurse
-		for child := node.FirstChild; child != nil; child = child.NextSibling {
-			process(child)
+// LoadFonts parses a comma separated list of font files.
+func LoadFonts(files string) ([]*sfnt.Font, error) {
+	var fonts []*sfnt.Font
+	for _, file := range strings.Split(files, ",") {
+		file = strings.TrimSpace(file)
+		if file == "" {
+			continue
+		}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
 		}
+		f, err := sfnt.Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", file, err)
+		}
+		fonts = append(fonts, f)
+	}
+	return fonts, nil
+}
 
-		if style := GetAttribute(node, "style"); style != nil {
-			style.Val = rxRemoveStyle.ReplaceAllString(style.Val, "")
-			style.Val = rxFillStyle.ReplaceAllStringFunc(style.Val, func(attribute string) string {
-				// fill:url(#xyz)
-				colorId := attribute[10 : len(attribute)-1]
+// UnifiedDiff returns the line differences of a and b in unified format.
+func UnifiedDiff(file, a, b string) string {
+	const context = 3
 
-				replacement := "fill:" + resolveColor(colorId)
-				changed = changed || (replacement != attribute)
-				return replacement
-			})
+	x, y := splitLines(a), splitLines(b)
+	edits := diffLines(x, y)
 
-			style.Val = rxStrokeStyle.ReplaceAllStringFunc(style.Val, func(attribute string) string {
-				// colore:url(#xyz)
-				colorId := attribute[12 : len(attribute)-1]
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- a/%s\n+++ b/%s\n", filepath.ToSlash(file), filepath.ToSlash(file))
 
-				replacement := "stroke:" + resolveColor(colorId)
-				changed = changed || (replacement != attribute)
-				return replacement
-			})
+	for i := 0; i < len(edits); {
+		if edits[i].op == ' ' {
+			i++
+			continue
 		}
-	}
 
-	for _, node := range nodes {
-		process(node)
-	}
-
-	// remove all dead gradients
-	for _, node := range nodes {
-		defss := GetElementsByTagName(node, "defs")
-		for _, defs := range defss {
-			toRemove := []*html.Node{}
-			for child := defs.FirstChild; child != nil; child = child.NextSibling {
-				if child.Data == "linearGradient" {
-					id := GetAttributeValue(child, "id")
-					noReplacement := "url(#" + id + ")"
-					if noReplacement != resolveColor(id) {
-						toRemove = append(toRemove, child)
-					}
+		// extend the hunk while the changes are close to each other
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for end < len(edits) {
+			if edits[end].op != ' ' {
+				end++
+				continue
+			}
+			next := end
+			for next < len(edits) && edits[next].op == ' ' {
+				next++
+			}
+			if next == len(edits) || next-end > 2*context {
+				end += context
+				if end > len(edits) {
+					end = len(edits)
 				}
+				break
 			}
+			end = next
+		}
 
-			for _, child := range toRemove {
-				changed = true
-				defs.RemoveChild(child)
+		hunk := edits[start:end]
+		fromLine, toLine := hunk[0].x+1, hunk[0].y+1
+		fromCount, toCount := 0, 0
+		for _, edit := range hunk {
+			if edit.op != '+' {
+				fromCount++
+			}
+			if edit.op != '-' {
+				toCount++
+			}
+		}
+		// empty ranges refer to the line before
+		if fromCount == 0 {
+			fromLine--
+		}
+		if toCount == 0 {
+			toLine--
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", fromLine, fromCount, toLine, toCount)
+		for _, edit := range hunk {
+			line := edit.line
+			if !strings.HasSuffix(line, "\n") {
+				line += "\n\\ No newline at end of file\n"
 			}
+			out.WriteByte(edit.op)
+			out.WriteString(line)
 		}
+		i = end
 	}
-
-	return changed
+	return out.String()
 }
 
-func GetAttributeValue(node *html.Node, name string) string {
-	for i := range node.Attr {
-		if node.Attr[i].Key == name {
-			return node.Attr[i].Val
-		}
-	}
-	return ""
+// edit is a single line in a diff, op is ' ', '-' or '+',
+// x and y are the line indices in the old and new text.
+type edit struct {
+	op   byte
+	x, y int
+	line string
 }
 
-func GetAttribute(node *html.Node, name string) *html.Attribute {
-	for i := range node.Attr {
-		if node.Attr[i].Key == name {
-			return &node.Attr[i]
-		}
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
 	}
-	return nil
+	return lines
 }
 
-func GetElementsByTagName(node *html.Node, tagname string) []*html.Node {
-	xs := []*html.Node{}
+// diffLines finds the longest common subsequence of lines,
+// large differences are treated as a full replacement.
+func diffLines(x, y []string) []edit {
+	var edits []edit
 
-	dataAtom := atom.Lookup([]byte(tagname))
-	if dataAtom != 0 {
-		tagname = ""
+	prefix := 0
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		edits = append(edits, edit{' ', prefix, prefix, x[prefix]})
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(x)-prefix && suffix < len(y)-prefix && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
 	}
 
-	for child := node.FirstChild; child != nil; child = child.NextSibling {
-		if child.Type != html.ElementNode {
-			continue
+	a, b := x[prefix:len(x)-suffix], y[prefix:len(y)-suffix]
+	if len(a)*len(b) > 1<<22 {
+		for i, line := range a {
+			edits = append(edits, edit{'-', prefix + i, prefix, line})
 		}
-		if child.DataAtom == dataAtom && child.Data == tagname {
-			xs = append(xs, child)
-			continue
+		for k, line := range b {
+			edits = append(edits, edit{'+', prefix + len(a), prefix + k, line})
 		}
+	} else {
+		// lcs[i][k] is the length of the common subsequence of a[i:] and b[k:]
+		lcs := make([][]int32, len(a)+1)
+		for i := range lcs {
+			lcs[i] = make([]int32, len(b)+1)
+		}
+		for i := len(a) - 1; i >= 0; i-- {
+			for k := len(b) - 1; k >= 0; k-- {
+				if a[i] == b[k] {
+					lcs[i][k] = lcs[i+1][k+1] + 1
+				} else if lcs[i+1][k] >= lcs[i][k+1] {
+					lcs[i][k] = lcs[i+1][k]
+				} else {
+					lcs[i][k] = lcs[i][k+1]
+				}
+			}
+		}
+
+		i, k := 0, 0
+		for i < len(a) || k < len(b) {
+			switch {
+			case i < len(a) && k < len(b) && a[i] == b[k]:
+				edits = append(edits, edit{' ', prefix + i, prefix + k, a[i]})
+				i, k = i+1, k+1
+			case k == len(b) || i < len(a) && lcs[i+1][k] >= lcs[i][k+1]:
+				edits = append(edits, edit{'-', prefix + i, prefix + k, a[i]})
+				i++
+			default:
+				edits = append(edits, edit{'+', prefix + i, prefix + k, b[k]})
+				k++
+			}
+		}
+	}
+
+	for s := suffix; s > 0; s-- {
+		edits = append(edits, edit{' ', len(x) - s, len(y) - s, x[len(x)-s]})
 	}
-	return xs
+	return edits
 }
 
 func check(err error) {
 	if err != nil {
-		panic(err)
+		fmt.Fprintf(os.Stderr, "failed: %v\n", err)
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,126 @@
+// lint-svg checks SVG files for problems with other tools.
+//
+//	go run lint-svg.go
+//	go run lint-svg.go -json -rules all,-font vector/fairy-tale
+//	go run lint-svg.go -list
+//
+// It exits with status 1 when any error is found.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/egonelbre/gophers/svg"
+)
+
+var (
+	rules  = flag.String("rules", "all", "comma separated rules to check, \"all\" or -name to exclude a rule")
+	list   = flag.Bool("list", false, "list available rules")
+	asJSON = flag.Bool("json", false, "print problems as JSON")
+	strict = flag.Bool("strict", false, "treat warnings as errors")
+)
+
+// FileProblem is a problem in a file.
+type FileProblem struct {
+	File string `json:"file"`
+	svg.Problem
+}
+
+func main() {
+	flag.Parse()
+
+	if *list {
+		for _, rule := range svg.Rules {
+			fmt.Printf("%-22s %-8s %s\n", rule.Name, rule.Severity, rule.Description)
+		}
+		return
+	}
+
+	selected, err := svg.SelectRules(*rules)
+	check(err)
+
+	roots := flag.Args()
+	if len(roots) == 0 {
+		roots = []string{"vector"}
+	}
+
+	var files []string
+	for _, root := range roots {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && filepath.Ext(path) == ".svg" {
+				files = append(files, path)
+			}
+			return nil
+		})
+		check(err)
+	}
+	sort.Strings(files)
+
+	problems := []FileProblem{}
+	for _, file := range files {
+		for _, problem := range LintFile(file, selected) {
+			problems = append(problems, FileProblem{File: file, Problem: problem})
+		}
+	}
+
+	failed := false
+	for _, problem := range problems {
+		if problem.Severity == svg.Error || *strict {
+			failed = true
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		check(enc.Encode(problems))
+	} else {
+		for _, problem := range problems {
+			location := problem.File
+			if problem.Line > 0 {
+				location += fmt.Sprintf(":%d", problem.Line)
+			}
+			fmt.Printf("%s: %s: %s: %s\n", location, problem.Severity, problem.Rule, problem.Message)
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// LintFile checks a single file, parse failures are reported as errors.
+func LintFile(file string, rules []*svg.Rule) []svg.Problem {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return []svg.Problem{{Rule: "read", Severity: svg.Error, Message: err.Error()}}
+	}
+
+	doc, err := svg.ParseString(string(data))
+	if err != nil {
+		problem := svg.Problem{Rule: "parse", Severity: svg.Error, Message: err.Error()}
+		if syntax, ok := err.(*svg.SyntaxError); ok {
+			problem.Line, problem.Message = syntax.Line, syntax.Msg
+		}
+		return []svg.Problem{problem}
+	}
+
+	return svg.Lint(doc, rules)
+}
+
+func check(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -1,61 +1,445 @@
 package main
 
 import (
+	"bytes"
 	"errors"
 	"flag"
 	"fmt"
 	"image"
+	"image/color"
 	"image/png"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
+
+	"github.com/egonelbre/gophers/internal/atomicfile"
+	"github.com/egonelbre/gophers/palette"
+	"github.com/egonelbre/gophers/pngopt"
+)
+
+var (
+	bleed  = flag.Int("bleed", 0, "alpha bleed iterations, spreads neighbouring colors into transparent pixels, -1 runs until everything is filled")
+	outdir = flag.String("out", "", "output directory, by default files are modified in place")
+	dryrun = flag.Bool("dry-run", false, "only report which files would change")
+
+	optimize = flag.Bool("optimize", false, "pick the smallest lossless encoding, otherwise the original color type and bit depth are kept")
+
+	matte         = flag.String("matte", "", "remove background color, e.g. #ffffff, from semi-transparent pixels")
+	premultiply   = flag.Bool("premultiply", false, "multiply color by alpha")
+	unpremultiply = flag.Bool("unpremultiply", false, "divide color by alpha")
+	threshold     = flag.Int("threshold", 0, "make pixels with alpha below threshold transparent and the rest opaque, 0 disables")
 )
 
-func handleFile(name string) error {
-	var source *image.NRGBA
-	{
-		file, err := os.Open(name)
+func handleFile(name, target string) error {
+	input, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	source, err := png.Decode(bytes.NewReader(input))
+	if err != nil {
+		return err
+	}
+
+	original := NewPixels(source)
+	pixels := NewPixels(source)
+
+	if *matte != "" {
+		c, err := palette.ParseColor(*matte)
 		if err != nil {
 			return err
 		}
+		dematte(pixels, color.NRGBA64{
+			R: uint16(c.R) * 0x101, G: uint16(c.G) * 0x101,
+			B: uint16(c.B) * 0x101, A: 0xffff,
+		})
+	}
+	if *premultiply {
+		premultiplyAlpha(pixels)
+	}
+	if *unpremultiply {
+		unpremultiplyAlpha(pixels)
+	}
+	if *threshold > 0 {
+		binarizeAlpha(pixels, uint16(*threshold)*0x101)
+	}
+
+	background := color.NRGBA64{}
+	if len(pixels.Pix) > 0 && pixels.Pix[0].A == 0 {
+		background = pixels.Pix[0]
+	}
+
+	for i := range pixels.Pix {
+		if pixels.Pix[i].A == 0 {
+			pixels.Pix[i] = background
+		}
+	}
+
+	if *bleed != 0 {
+		alphaBleed(pixels, *bleed)
+	}
+
+	var output bytes.Buffer
+	if *optimize {
+		err = pngopt.Encode(&output, pixels.Convert(source))
+	} else {
+		err = png.Encode(&output, pixels.Convert(source))
+	}
+	if err != nil {
+		return err
+	}
+
+	// re-encoding rarely reproduces the same bytes, so compare pixels
+	existing, existingSize := original, len(input)
+	if target != name {
+		existing = nil
+		if data, err := ioutil.ReadFile(target); err == nil {
+			if m, err := png.Decode(bytes.NewReader(data)); err == nil {
+				existing, existingSize = NewPixels(m), len(data)
+			}
+		}
+	}
+	if existing != nil && pixels.Equal(existing) {
+		// optimizing is still worth it, when it makes the file smaller
+		if !*optimize || output.Len() >= existingSize {
+			return nil
+		}
+	}
+
+	if *dryrun {
+		changed := 0
+		for i := range pixels.Pix {
+			if pixels.Pix[i] != original.Pix[i] {
+				changed++
+			}
+		}
+		fmt.Printf("%v: would write %v, %d pixels changed\n", name, target, changed)
+		return nil
+	}
 
-		m, err := png.Decode(file)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(target, output.Bytes())
+}
+
+// commonDir returns the deepest directory containing all paths.
+func commonDir(paths []string) (string, error) {
+	var common []string
+	for i, path := range paths {
+		abs, err := filepath.Abs(path)
 		if err != nil {
-			file.Close()
-			return err
+			return "", err
+		}
+		parts := strings.Split(filepath.Dir(abs), string(filepath.Separator))
+		if i == 0 {
+			common = parts
+			continue
+		}
+		n := 0
+		for n < len(common) && n < len(parts) && common[n] == parts[n] {
+			n++
 		}
-		file.Close()
+		common = common[:n]
+	}
+	dir := strings.Join(common, string(filepath.Separator))
+	if dir == "" {
+		dir = string(filepath.Separator)
+	}
+	return dir, nil
+}
 
-		if rgba, ok := m.(*image.NRGBA); !ok {
-			return errors.New("not RGBA")
-		} else {
-			source = rgba
+// outputName returns where input is written in -out, keeping
+// its path relative to base, so that equal names don't collide.
+func outputName(base, input string) string {
+	rel := filepath.Base(input)
+	if abs, err := filepath.Abs(input); err == nil {
+		if r, err := filepath.Rel(base, abs); err == nil {
+			rel = r
 		}
 	}
+	return filepath.Join(*outdir, rel)
+}
+
+// Pixels is an image in non-premultiplied 16-bit color,
+// so that every PNG color model can be processed the same way.
+type Pixels struct {
+	Rect image.Rectangle
+	Pix  []color.NRGBA64
+}
 
-	background := [4]uint8{0, 0, 0, 0}
-	if source.Pix[3] == 0 {
-		copy(background[:], source.Pix[:4])
+func NewPixels(m image.Image) *Pixels {
+	b := m.Bounds()
+	pixels := &Pixels{
+		Rect: b,
+		Pix:  make([]color.NRGBA64, 0, b.Dx()*b.Dy()),
 	}
 
-	for i := 0; i < len(source.Pix); i += 4 {
-		if source.Pix[i+3] == 0 {
-			copy(source.Pix[i:i+4], background[:])
+	// keep the color of transparent pixels, which would be lost by
+	// the generic conversion through premultiplied color
+	switch m := m.(type) {
+	case *image.NRGBA:
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				c := m.NRGBAAt(x, y)
+				pixels.Pix = append(pixels.Pix, color.NRGBA64{
+					R: uint16(c.R) * 0x101, G: uint16(c.G) * 0x101,
+					B: uint16(c.B) * 0x101, A: uint16(c.A) * 0x101,
+				})
+			}
+		}
+	case *image.NRGBA64:
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				pixels.Pix = append(pixels.Pix, m.NRGBA64At(x, y))
+			}
+		}
+	default:
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				c := color.NRGBA64Model.Convert(m.At(x, y)).(color.NRGBA64)
+				pixels.Pix = append(pixels.Pix, c)
+			}
 		}
 	}
 
-	{
-		file, err := os.Create(name)
-		if err != nil {
-			return err
+	return pixels
+}
+
+// Equal reports whether both have the same size and pixels.
+func (pixels *Pixels) Equal(other *Pixels) bool {
+	if pixels.Rect.Size() != other.Rect.Size() || len(pixels.Pix) != len(other.Pix) {
+		return false
+	}
+	for i, c := range pixels.Pix {
+		if c != other.Pix[i] {
+			return false
 		}
-		defer file.Close()
+	}
+	return true
+}
 
-		if err := png.Encode(file, source); err != nil {
-			return err
+// Convert returns pixels in the same color model as original, so that
+// the PNG is written back with the same color type and bit depth.
+// Paletted images fall back to NRGBA when the colors don't fit the palette.
+func (pixels *Pixels) Convert(original image.Image) image.Image {
+	b := pixels.Rect
+	at := func(x, y int) color.NRGBA64 {
+		return pixels.Pix[(y-b.Min.Y)*b.Dx()+(x-b.Min.X)]
+	}
+
+	switch original := original.(type) {
+	case *image.Gray:
+		m := image.NewGray(b)
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				m.Set(x, y, at(x, y))
+			}
+		}
+		return m
+	case *image.Gray16:
+		m := image.NewGray16(b)
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				m.Set(x, y, at(x, y))
+			}
 		}
+		return m
+	case *image.NRGBA64, *image.RGBA64:
+		m := image.NewNRGBA64(b)
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				m.SetNRGBA64(x, y, at(x, y))
+			}
+		}
+		return m
+	case *image.Paletted:
+		if m, ok := pixels.toPaletted(original.Palette); ok {
+			return m
+		}
+	}
+
+	m := image.NewNRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := at(x, y)
+			m.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(c.R >> 8), G: uint8(c.G >> 8),
+				B: uint8(c.B >> 8), A: uint8(c.A >> 8),
+			})
+		}
+	}
+	return m
+}
+
+// toPaletted maps pixels to palette, adding missing colors while there's room.
+func (pixels *Pixels) toPaletted(palette color.Palette) (*image.Paletted, bool) {
+	palette = append(color.Palette{}, palette...)
+
+	index := map[color.NRGBA]uint8{}
+	for i := len(palette) - 1; i >= 0; i-- {
+		index[color.NRGBAModel.Convert(palette[i]).(color.NRGBA)] = uint8(i)
+	}
+
+	m := image.NewPaletted(pixels.Rect, nil)
+	for i, c := range pixels.Pix {
+		key := color.NRGBA{
+			R: uint8(c.R >> 8), G: uint8(c.G >> 8),
+			B: uint8(c.B >> 8), A: uint8(c.A >> 8),
+		}
+		if key.A == 0 {
+			// the transparent palette entries are equivalent
+			key = color.NRGBA{}
+			for k, p := range palette {
+				if _, _, _, a := p.RGBA(); a == 0 {
+					key = color.NRGBAModel.Convert(palette[k]).(color.NRGBA)
+					break
+				}
+			}
+		}
+
+		k, ok := index[key]
+		if !ok {
+			if len(palette) >= 256 {
+				return nil, false
+			}
+			k = uint8(len(palette))
+			palette = append(palette, key)
+			index[key] = k
+		}
+		m.Pix[i] = k
 	}
+	m.Palette = palette
+
+	return m, true
+}
 
-	return nil
+// dematte removes matte color from semi-transparent pixels, which
+// were composited over it, by solving c = alpha*f + (1-alpha)*matte for f.
+func dematte(m *Pixels, matte color.NRGBA64) {
+	solve := func(c, bg uint16, alpha uint32) uint16 {
+		v := (int64(c)*0xffff - int64(bg)*int64(0xffff-alpha)) / int64(alpha)
+		if v < 0 {
+			return 0
+		}
+		if v > 0xffff {
+			return 0xffff
+		}
+		return uint16(v)
+	}
+
+	for i, c := range m.Pix {
+		if c.A == 0 || c.A == 0xffff {
+			continue
+		}
+		alpha := uint32(c.A)
+		m.Pix[i].R = solve(c.R, matte.R, alpha)
+		m.Pix[i].G = solve(c.G, matte.G, alpha)
+		m.Pix[i].B = solve(c.B, matte.B, alpha)
+	}
+}
+
+func premultiplyAlpha(m *Pixels) {
+	for i, c := range m.Pix {
+		a := uint32(c.A)
+		m.Pix[i].R = uint16(uint32(c.R) * a / 0xffff)
+		m.Pix[i].G = uint16(uint32(c.G) * a / 0xffff)
+		m.Pix[i].B = uint16(uint32(c.B) * a / 0xffff)
+	}
+}
+
+func unpremultiplyAlpha(m *Pixels) {
+	div := func(v uint16, a uint32) uint16 {
+		r := uint32(v) * 0xffff / a
+		if r > 0xffff {
+			return 0xffff
+		}
+		return uint16(r)
+	}
+
+	for i, c := range m.Pix {
+		if c.A == 0 || c.A == 0xffff {
+			continue
+		}
+		a := uint32(c.A)
+		m.Pix[i].R = div(c.R, a)
+		m.Pix[i].G = div(c.G, a)
+		m.Pix[i].B = div(c.B, a)
+	}
+}
+
+// binarizeAlpha makes every pixel either fully transparent or fully opaque.
+func binarizeAlpha(m *Pixels, threshold uint16) {
+	for i, c := range m.Pix {
+		if c.A < threshold {
+			m.Pix[i].A = 0
+		} else {
+			m.Pix[i].A = 0xffff
+		}
+	}
+}
+
+// alphaBleed fills color of fully transparent pixels with the average
+// of their already filled neighbours, growing from visible pixels
+// one pixel per iteration. Negative iterations run until no pixel changes.
+func alphaBleed(m *Pixels, iterations int) {
+	w, h := m.Rect.Dx(), m.Rect.Dy()
+
+	filled := make([]bool, w*h)
+	pending := []int{}
+	for i, c := range m.Pix {
+		if c.A != 0 {
+			filled[i] = true
+		} else {
+			pending = append(pending, i)
+		}
+	}
+
+	for iteration := 0; iterations < 0 || iteration < iterations; iteration++ {
+		type fill struct {
+			index int
+			color color.NRGBA64
+		}
+		fills := []fill{}
+		remaining := pending[:0]
+
+		for _, index := range pending {
+			x, y := index%w, index/w
+
+			var r, g, b, n int
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := x+dx, y+dy
+					if nx < 0 || ny < 0 || nx >= w || ny >= h || !filled[ny*w+nx] {
+						continue
+					}
+					c := m.Pix[ny*w+nx]
+					r, g, b = r+int(c.R), g+int(c.G), b+int(c.B)
+					n++
+				}
+			}
+
+			if n == 0 {
+				remaining = append(remaining, index)
+				continue
+			}
+			fills = append(fills, fill{index, color.NRGBA64{
+				R: uint16(r / n), G: uint16(g / n), B: uint16(b / n),
+			}})
+		}
+
+		if len(fills) == 0 {
+			break
+		}
+
+		// apply after the pass, so that every iteration grows by exactly one pixel
+		for _, f := range fills {
+			m.Pix[f.index] = f.color
+			filled[f.index] = true
+		}
+		pending = remaining
+	}
 }
 
 func main() {
@@ -66,10 +450,26 @@ func main() {
 		os.Exit(1)
 	}
 
+	if *premultiply && *unpremultiply {
+		check(errors.New("-premultiply and -unpremultiply are exclusive"))
+	}
+	if _, err := palette.ParseColor(*matte); *matte != "" && err != nil {
+		check(err)
+	}
+	if *threshold < 0 || *threshold > 255 {
+		check(fmt.Errorf("-threshold must be in range 0-255, got %d", *threshold))
+	}
+
 	matches, err := filepath.Glob(flag.Arg(0))
 	check(err)
+	base, err := commonDir(matches)
+	check(err)
 	for _, match := range matches {
-		err := handleFile(match)
+		target := match
+		if *outdir != "" {
+			target = outputName(base, match)
+		}
+		err := handleFile(match, target)
 		if err != nil {
 			fmt.Printf("%v: %v\n", match, err)
 		}
@@ -0,0 +1,76 @@
+// optimize-png losslessly recompresses PNG files and strips their metadata.
+//
+//	go run optimize-png.go icon/*.png animation/2bit-sprite/*.png
+//
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/egonelbre/gophers/internal/atomicfile"
+	"github.com/egonelbre/gophers/pngopt"
+)
+
+var (
+	dryrun = flag.Bool("dry-run", false, "only report how much would be saved")
+)
+
+func handleFile(name string) (before, after int, err error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	optimized, err := pngopt.Optimize(data)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if len(optimized) >= len(data) || *dryrun {
+		return len(data), len(optimized), nil
+	}
+
+	return len(data), len(optimized), atomicfile.WriteFile(name, optimized)
+}
+
+func main() {
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	total, saved := 0, 0
+	for _, arg := range flag.Args() {
+		matches, err := filepath.Glob(arg)
+		check(err)
+		for _, match := range matches {
+			before, after, err := handleFile(match)
+			if err != nil {
+				fmt.Printf("%v: %v\n", match, err)
+				continue
+			}
+
+			total += before
+			if after < before {
+				saved += before - after
+				fmt.Printf("%v: %d -> %d bytes\n", match, before, after)
+			}
+		}
+	}
+
+	fmt.Printf("saved %d of %d bytes\n", saved, total)
+}
+
+func check(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed: %v\n", err)
+		os.Exit(1)
+	}
+}
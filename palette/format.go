@@ -0,0 +1,271 @@
+package palette
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+const (
+	aseMagic      = 0xA5E0
+	aseFrameMagic = 0xF1FA
+
+	aseChunkOldPalette64  = 0x0011
+	aseChunkOldPalette256 = 0x0004
+	aseChunkPalette       = 0x2019
+)
+
+// ReadASE reads the palette of the first frame of an Aseprite file.
+func ReadASE(r io.Reader) (color.Palette, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	le := binary.LittleEndian
+	if len(data) < 128+16 || le.Uint16(data[4:]) != aseMagic {
+		return nil, errors.New("not an aseprite file")
+	}
+
+	frame := data[128:]
+	if le.Uint16(frame[4:]) != aseFrameMagic {
+		return nil, errors.New("invalid aseprite frame")
+	}
+	frameSize := int(le.Uint32(frame[0:]))
+	if frameSize > len(frame) {
+		return nil, errors.New("truncated aseprite frame")
+	}
+	chunkCount := int(le.Uint32(frame[12:]))
+	if chunkCount == 0 {
+		chunkCount = int(le.Uint16(frame[6:]))
+	}
+
+	var palette, old color.Palette
+	chunks := frame[16:frameSize]
+	for i := 0; i < chunkCount && len(chunks) >= 6; i++ {
+		size := int(le.Uint32(chunks[0:]))
+		if size < 6 || size > len(chunks) {
+			return nil, errors.New("invalid aseprite chunk")
+		}
+		kind := le.Uint16(chunks[4:])
+		body := chunks[6:size]
+		chunks = chunks[size:]
+
+		switch kind {
+		case aseChunkPalette:
+			if len(body) < 20 {
+				return nil, errors.New("invalid aseprite palette chunk")
+			}
+			total := int(le.Uint32(body[0:]))
+			first := int(le.Uint32(body[4:]))
+			last := int(le.Uint32(body[8:]))
+			if first > last || last >= total {
+				return nil, errors.New("invalid aseprite palette range")
+			}
+			if total > MaxColors {
+				return nil, fmt.Errorf("aseprite palette has %d colors, at most %d are supported", total, MaxColors)
+			}
+
+			if len(palette) < total {
+				palette = append(palette, make(color.Palette, total-len(palette))...)
+			}
+
+			entries := body[20:]
+			for k := first; k <= last; k++ {
+				if len(entries) < 6 {
+					return nil, errors.New("truncated aseprite palette")
+				}
+				flags := le.Uint16(entries[0:])
+				palette[k] = color.NRGBA{entries[2], entries[3], entries[4], entries[5]}
+				entries = entries[6:]
+
+				if flags&1 != 0 {
+					if len(entries) < 2 {
+						return nil, errors.New("truncated aseprite palette name")
+					}
+					n := int(le.Uint16(entries[0:]))
+					if len(entries) < 2+n {
+						return nil, errors.New("truncated aseprite palette name")
+					}
+					entries = entries[2+n:]
+				}
+			}
+		case aseChunkOldPalette256, aseChunkOldPalette64:
+			scale := 1
+			if kind == aseChunkOldPalette64 {
+				scale = 4
+			}
+
+			if len(body) < 2 {
+				return nil, errors.New("invalid aseprite palette chunk")
+			}
+			packets := int(le.Uint16(body[0:]))
+			body = body[2:]
+
+			index := 0
+			for p := 0; p < packets && len(body) >= 2; p++ {
+				index += int(body[0])
+				count := int(body[1])
+				if count == 0 {
+					count = 256
+				}
+				body = body[2:]
+				for k := 0; k < count && len(body) >= 3; k++ {
+					for len(old) <= index {
+						old = append(old, color.NRGBA{A: 0xff})
+					}
+					old[index] = color.NRGBA{
+						R: clamp8(int(body[0]) * scale),
+						G: clamp8(int(body[1]) * scale),
+						B: clamp8(int(body[2]) * scale),
+						A: 0xff,
+					}
+					body = body[3:]
+					index++
+				}
+			}
+		}
+	}
+
+	// newer files contain both chunks, the new one supports alpha
+	if palette == nil {
+		palette = old
+	}
+	for i, c := range palette {
+		if c == nil {
+			palette[i] = color.NRGBA{}
+		}
+	}
+	return palette, nil
+}
+
+func clamp8(v int) uint8 {
+	if v > 255 {
+		return 255
+	}
+	return uint8(v)
+}
+
+// ReadGPL reads a GIMP palette.
+func ReadGPL(r io.Reader) (color.Palette, error) {
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != "GIMP Palette" {
+		return nil, errors.New("not a GIMP palette")
+	}
+
+	palette := color.Palette{}
+	for line := 2; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") ||
+			strings.HasPrefix(text, "Name:") || strings.HasPrefix(text, "Columns:") {
+			continue
+		}
+
+		c, err := parseRGB(strings.Fields(text))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		palette = append(palette, c)
+	}
+	return palette, scanner.Err()
+}
+
+// WriteGPL writes palette as a GIMP palette.
+func WriteGPL(w io.Writer, name string, palette color.Palette) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "GIMP Palette\nName: %v\nColumns: 16\n#\n", name)
+	for _, c := range palette {
+		n := color.NRGBAModel.Convert(c).(color.NRGBA)
+		fmt.Fprintf(bw, "%3d %3d %3d\t#%02x%02x%02x\n", n.R, n.G, n.B, n.R, n.G, n.B)
+	}
+	return bw.Flush()
+}
+
+// ReadJASC reads a JASC (Paint Shop Pro) palette.
+func ReadJASC(r io.Reader) (color.Palette, error) {
+	scanner := bufio.NewScanner(r)
+
+	header := []string{}
+	for len(header) < 3 && scanner.Scan() {
+		header = append(header, strings.TrimSpace(scanner.Text()))
+	}
+	if len(header) < 3 || header[0] != "JASC-PAL" {
+		return nil, errors.New("not a JASC palette")
+	}
+	count, err := strconv.Atoi(header[2])
+	if err != nil || count < 0 {
+		return nil, fmt.Errorf("invalid color count %q", header[2])
+	}
+
+	palette := color.Palette{}
+	for line := 4; len(palette) < count && scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		c, err := parseRGB(strings.Fields(text))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		palette = append(palette, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(palette) != count {
+		return nil, fmt.Errorf("expected %d colors, got %d", count, len(palette))
+	}
+	return palette, nil
+}
+
+func parseRGB(fields []string) (color.Color, error) {
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("expected r g b, got %q", strings.Join(fields, " "))
+	}
+
+	var rgb [3]uint8
+	for i := range rgb {
+		v, err := strconv.Atoi(fields[i])
+		if err != nil || v < 0 || v > 255 {
+			return nil, fmt.Errorf("invalid color component %q", fields[i])
+		}
+		rgb[i] = uint8(v)
+	}
+	return color.NRGBA{rgb[0], rgb[1], rgb[2], 0xff}, nil
+}
+
+// ParseColor parses white, black, transparent, #rgb, #rgba, #rrggbb or #rrggbbaa.
+func ParseColor(s string) (color.NRGBA, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "white":
+		return color.NRGBA{0xff, 0xff, 0xff, 0xff}, nil
+	case "black":
+		return color.NRGBA{0x00, 0x00, 0x00, 0xff}, nil
+	case "transparent":
+		return color.NRGBA{}, nil
+	}
+
+	hex := strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(hex) == 3 || len(hex) == 4 {
+		long := make([]byte, 0, 2*len(hex))
+		for i := 0; i < len(hex); i++ {
+			long = append(long, hex[i], hex[i])
+		}
+		hex = string(long)
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 8 {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q", s)
+	}
+	return color.NRGBA{uint8(v >> 24), uint8(v >> 16), uint8(v >> 8), uint8(v)}, nil
+}
@@ -0,0 +1,207 @@
+// Package palette reads, extracts and applies color palettes.
+//
+// Palettes can be loaded from Aseprite (.ase, .aseprite), GIMP (.gpl),
+// JASC (.pal) and image files. Images can be remapped to a palette with
+// optional Floyd-Steinberg or ordered Bayer dithering.
+package palette
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// MaxColors is the largest supported palette, since
+// paletted images index colors with a byte.
+const MaxColors = 256
+
+// Load reads a palette from file, the format is determined by the extension.
+func Load(path string) (color.Palette, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var palette color.Palette
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ase", ".aseprite":
+		palette, err = ReadASE(file)
+	case ".gpl":
+		palette, err = ReadGPL(file)
+	case ".pal":
+		palette, err = ReadJASC(file)
+	default:
+		var m image.Image
+		m, _, err = image.Decode(file)
+		if err == nil {
+			palette, err = FromImage(m)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	if len(palette) == 0 {
+		return nil, fmt.Errorf("%v: empty palette", path)
+	}
+	if len(palette) > MaxColors {
+		return nil, fmt.Errorf("%v: palette has %d colors, at most %d are supported", path, len(palette), MaxColors)
+	}
+	return palette, nil
+}
+
+// FromImage returns the palette of a paletted image or
+// the colors of m in scan order, when there are at most 256.
+func FromImage(m image.Image) (color.Palette, error) {
+	if p, ok := m.(*image.Paletted); ok {
+		return p.Palette, nil
+	}
+
+	palette := Unique(m, 256)
+	if palette == nil {
+		return nil, errors.New("image has more than 256 colors")
+	}
+	return palette, nil
+}
+
+// Unique returns the colors of m in scan order or nil,
+// when there are more than max colors.
+func Unique(m image.Image, max int) color.Palette {
+	b := m.Bounds()
+	seen := map[color.NRGBA]bool{}
+	palette := color.Palette{}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(m.At(x, y)).(color.NRGBA)
+			if c.A == 0 {
+				c = color.NRGBA{}
+			}
+			if seen[c] {
+				continue
+			}
+			if len(palette) >= max {
+				return nil
+			}
+			seen[c] = true
+			palette = append(palette, c)
+		}
+	}
+	return palette
+}
+
+// Extract returns at most n colors representing m. When m has more
+// colors they are reduced with median cut. Transparency is kept
+// as a single fully transparent entry.
+func Extract(m image.Image, n int) color.Palette {
+	if palette := Unique(m, n); palette != nil {
+		return palette
+	}
+
+	b := m.Bounds()
+	transparent := false
+	pixels := []color.NRGBA{}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(m.At(x, y)).(color.NRGBA)
+			if c.A < 0x80 {
+				transparent = true
+				continue
+			}
+			pixels = append(pixels, c)
+		}
+	}
+
+	if transparent {
+		n--
+	}
+	palette := medianCut(pixels, n)
+	if transparent {
+		palette = append(color.Palette{color.NRGBA{}}, palette...)
+	}
+	return palette
+}
+
+// medianCut splits the box with the widest channel range
+// at its median until there are n boxes.
+func medianCut(pixels []color.NRGBA, n int) color.Palette {
+	if len(pixels) == 0 || n <= 0 {
+		return color.Palette{}
+	}
+
+	channel := func(c color.NRGBA, k int) uint8 {
+		switch k {
+		case 0:
+			return c.R
+		case 1:
+			return c.G
+		}
+		return c.B
+	}
+
+	widest := func(box []color.NRGBA) (k int, spread int) {
+		for ch := 0; ch < 3; ch++ {
+			lo, hi := uint8(255), uint8(0)
+			for _, c := range box {
+				v := channel(c, ch)
+				if v < lo {
+					lo = v
+				}
+				if v > hi {
+					hi = v
+				}
+			}
+			if int(hi)-int(lo) > spread {
+				k, spread = ch, int(hi)-int(lo)
+			}
+		}
+		return k, spread
+	}
+
+	boxes := [][]color.NRGBA{pixels}
+	for len(boxes) < n {
+		best, bestSpread, bestChannel := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			if ch, spread := widest(box); spread > bestSpread {
+				best, bestSpread, bestChannel = i, spread, ch
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		box := boxes[best]
+		sort.Slice(box, func(i, k int) bool {
+			return channel(box[i], bestChannel) < channel(box[k], bestChannel)
+		})
+		mid := len(box) / 2
+		boxes[best] = box[:mid]
+		boxes = append(boxes, box[mid:])
+	}
+
+	palette := make(color.Palette, 0, len(boxes))
+	for _, box := range boxes {
+		var r, g, b int
+		for _, c := range box {
+			r, g, b = r+int(c.R), g+int(c.G), b+int(c.B)
+		}
+		palette = append(palette, color.NRGBA{
+			R: uint8(r / len(box)),
+			G: uint8(g / len(box)),
+			B: uint8(b / len(box)),
+			A: 0xff,
+		})
+	}
+	return palette
+}
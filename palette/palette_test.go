@@ -0,0 +1,221 @@
+package palette
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadASE(t *testing.T) {
+	data, err := ioutil.ReadFile("../animation/palette.ase")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	palette, err := ReadASE(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(palette) != 48 {
+		t.Fatalf("got %d colors, want 48", len(palette))
+	}
+	for i, want := range map[int]color.NRGBA{
+		0:  {0xff, 0x00, 0xff, 0x00},
+		4:  {0xff, 0xff, 0xff, 0xff},
+		12: {0x96, 0xd6, 0xff, 0xff},
+		42: {0xbd, 0x4b, 0x8c, 0xff},
+	} {
+		if palette[i] != want {
+			t.Errorf("color %d: got %v, want %v", i, palette[i], want)
+		}
+	}
+
+	// the palette chunk of the first frame starts at 0x90
+	large := append([]byte{}, data...)
+	large[0x96], large[0x97] = 0x01, 0x01
+	if _, err := ReadASE(bytes.NewReader(large)); err == nil {
+		t.Errorf("expected an error for a palette with 257 colors")
+	}
+
+	for _, bad := range [][]byte{nil, data[:100], data[:0x90+10]} {
+		if _, err := ReadASE(bytes.NewReader(bad)); err == nil {
+			t.Errorf("expected an error for %d bytes", len(bad))
+		}
+	}
+}
+
+func TestGPL(t *testing.T) {
+	palette := color.Palette{
+		color.NRGBA{0x00, 0x00, 0x00, 0xff},
+		color.NRGBA{0x12, 0x34, 0x56, 0xff},
+		color.NRGBA{0xff, 0xff, 0xff, 0xff},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteGPL(&buf, "test", palette); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadGPL(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(palette) {
+		t.Errorf("got %v, want %v", got, palette)
+	}
+
+	if _, err := ReadGPL(strings.NewReader("GIMP Palette\n1 2\n")); err == nil {
+		t.Errorf("expected an error for a missing component")
+	}
+}
+
+func TestReadJASC(t *testing.T) {
+	palette, err := ReadJASC(strings.NewReader("JASC-PAL\n0100\n2\n0 0 0\n255 128 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := color.Palette{color.NRGBA{0, 0, 0, 0xff}, color.NRGBA{255, 128, 1, 0xff}}
+	if fmt.Sprint(palette) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", palette, want)
+	}
+
+	for _, bad := range []string{"JASC-PAL\n0100\n3\n0 0 0\n", "JASC-PAL\n0100\n1\n0 0 256\n", "RIFF"} {
+		if _, err := ReadJASC(strings.NewReader(bad)); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "palette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name string, colors int) string {
+		var b strings.Builder
+		b.WriteString("GIMP Palette\n")
+		for i := 0; i < colors; i++ {
+			fmt.Fprintf(&b, "%d %d %d\n", i%256, i/256, 0)
+		}
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(b.String()), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	if palette, err := Load(write("full.gpl", MaxColors)); err != nil || len(palette) != MaxColors {
+		t.Errorf("got %d colors, %v", len(palette), err)
+	}
+	if _, err := Load(write("large.gpl", MaxColors+1)); err == nil {
+		t.Errorf("expected an error for %d colors", MaxColors+1)
+	}
+	if _, err := Load(write("empty.gpl", 0)); err == nil {
+		t.Errorf("expected an error for an empty palette")
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		in   string
+		want color.NRGBA
+	}{
+		{"white", color.NRGBA{0xff, 0xff, 0xff, 0xff}},
+		{" Black ", color.NRGBA{0x00, 0x00, 0x00, 0xff}},
+		{"transparent", color.NRGBA{}},
+		{"#f80", color.NRGBA{0xff, 0x88, 0x00, 0xff}},
+		{"#f808", color.NRGBA{0xff, 0x88, 0x00, 0x88}},
+		{"#123456", color.NRGBA{0x12, 0x34, 0x56, 0xff}},
+		{"12345678", color.NRGBA{0x12, 0x34, 0x56, 0x78}},
+	}
+	for _, test := range tests {
+		got, err := ParseColor(test.in)
+		if err != nil || got != test.want {
+			t.Errorf("%q: got %v, %v, want %v", test.in, got, err, test.want)
+		}
+	}
+
+	for _, bad := range []string{"", "#12", "#12345", "#gggggg", "red", "#123456789"} {
+		if got, err := ParseColor(bad); err == nil {
+			t.Errorf("%q: expected an error, got %v", bad, got)
+		}
+	}
+}
+
+func TestRemap(t *testing.T) {
+	palette := color.Palette{color.NRGBA{0, 0, 0, 0xff}, color.NRGBA{0xff, 0xff, 0xff, 0xff}}
+
+	m := image.NewNRGBA(image.Rect(0, 0, 4, 1))
+	m.Set(0, 0, color.NRGBA{0x10, 0x10, 0x10, 0xff})
+	m.Set(1, 0, color.NRGBA{0xf0, 0xf0, 0xf0, 0xff})
+	m.Set(2, 0, color.NRGBA{0x00, 0x00, 0x00, 0xff})
+	m.Set(3, 0, color.NRGBA{0xff, 0xff, 0xff, 0xff})
+
+	for _, name := range []string{"none", "fs", "bayer2", "bayer4", "bayer8"} {
+		dither, ok := ParseDither(name)
+		if !ok {
+			t.Fatalf("unknown dither %q", name)
+		}
+		got := Remap(m, palette, dither)
+		if want := []uint8{0, 1, 0, 1}; !bytes.Equal(got.Pix, want) {
+			t.Errorf("%s: got %v, want %v", name, got.Pix, want)
+		}
+	}
+
+	if _, ok := ParseDither("random"); ok {
+		t.Errorf("expected random to be unknown")
+	}
+}
+
+func TestRemapBayerKeepsPaletteColors(t *testing.T) {
+	palettes := map[string]color.Palette{
+		"black and red": {color.NRGBA{0, 0, 0, 0xff}, color.NRGBA{0xff, 0, 0, 0xff}},
+		"game boy": {
+			color.NRGBA{0x0f, 0x38, 0x0f, 0xff}, color.NRGBA{0x30, 0x62, 0x30, 0xff},
+			color.NRGBA{0x8b, 0xac, 0x0f, 0xff}, color.NRGBA{0x9b, 0xbc, 0x0f, 0xff},
+		},
+		"gray": {
+			color.NRGBA{}, color.NRGBA{0, 0, 0, 0xff}, color.NRGBA{0x55, 0x55, 0x55, 0xff},
+			color.NRGBA{0xaa, 0xaa, 0xaa, 0xff}, color.NRGBA{0xff, 0xff, 0xff, 0xff},
+		},
+	}
+	for name, palette := range palettes {
+		for index, c := range palette {
+			fill := image.NewUniform(c)
+			m := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+			draw.Draw(m, m.Bounds(), fill, image.Point{}, draw.Src)
+
+			for _, dither := range []Dither{Bayer2, Bayer4, Bayer8} {
+				got := Remap(m, palette, dither)
+				for i, v := range got.Pix {
+					if int(v) != index {
+						t.Errorf("%s: bayer %d moved color %d to %d at pixel %d", name, dither, index, v, i)
+						break
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestRemapBayerMixes(t *testing.T) {
+	palette := color.Palette{color.NRGBA{0, 0, 0, 0xff}, color.NRGBA{0xff, 0, 0, 0xff}}
+	m := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	draw.Draw(m, m.Bounds(), image.NewUniform(color.NRGBA{0x80, 0, 0, 0xff}), image.Point{}, draw.Src)
+
+	counts := [2]int{}
+	for _, v := range Remap(m, palette, Bayer8).Pix {
+		counts[v]++
+	}
+	if counts[0] < 16 || counts[1] < 16 {
+		t.Errorf("half red became %d black and %d red pixels", counts[0], counts[1])
+	}
+}
@@ -0,0 +1,149 @@
+package palette
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// Dither selects how colors missing from the palette are approximated.
+type Dither int
+
+const (
+	None Dither = iota
+	FloydSteinberg
+	Bayer2
+	Bayer4
+	Bayer8
+)
+
+// ParseDither parses none, floyd-steinberg, bayer2, bayer4 or bayer8.
+func ParseDither(name string) (Dither, bool) {
+	switch name {
+	case "", "none":
+		return None, true
+	case "floyd-steinberg", "fs":
+		return FloydSteinberg, true
+	case "bayer2":
+		return Bayer2, true
+	case "bayer4", "bayer":
+		return Bayer4, true
+	case "bayer8":
+		return Bayer8, true
+	}
+	return None, false
+}
+
+// Remap converts m to the palette using dither,
+// the palette must have at most MaxColors colors.
+func Remap(m image.Image, palette color.Palette, dither Dither) *image.Paletted {
+	b := m.Bounds()
+	dst := image.NewPaletted(b, palette)
+
+	switch dither {
+	case FloydSteinberg:
+		draw.FloydSteinberg.Draw(dst, b, m, b.Min)
+	case Bayer2, Bayer4, Bayer8:
+		size := map[Dither]int{Bayer2: 2, Bayer4: 4, Bayer8: 8}[dither]
+		matrix := bayer(size)
+		spreads := paletteSpreads(palette)
+
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				c := color.NRGBAModel.Convert(m.At(x, y)).(color.NRGBA)
+				if c.A != 0 && c.A != 0xff {
+					c.A = 0xff
+				}
+				if c.A != 0 {
+					// threshold in range -0.5..0.5 of the spacing around the nearest color
+					spread := spreads[palette.Index(c)]
+					t := (matrix[(y-b.Min.Y)%size][(x-b.Min.X)%size] - 0.5) * spread
+					c.R = clampf(float64(c.R) + t)
+					c.G = clampf(float64(c.G) + t)
+					c.B = clampf(float64(c.B) + t)
+				}
+				dst.SetColorIndex(x, y, uint8(palette.Index(c)))
+			}
+		}
+	default:
+		draw.Draw(dst, b, m, b.Min, draw.Src)
+	}
+
+	return dst
+}
+
+// bayer returns the normalized ordered dithering matrix of size 2, 4 or 8.
+func bayer(size int) [][]float64 {
+	m := [][]int{{0}}
+	for n := 1; n < size; n *= 2 {
+		next := make([][]int, 2*n)
+		for y := range next {
+			next[y] = make([]int, 2*n)
+			for x := range next[y] {
+				v := 4 * m[y%n][x%n]
+				switch {
+				case y < n && x >= n:
+					v += 2
+				case y >= n && x < n:
+					v += 3
+				case y >= n && x >= n:
+					v++
+				}
+				next[y][x] = v
+			}
+		}
+		m = next
+	}
+
+	result := make([][]float64, size)
+	for y := range result {
+		result[y] = make([]float64, size)
+		for x := range result[y] {
+			result[y][x] = (float64(m[y][x]) + 0.5) / float64(size*size)
+		}
+	}
+	return result
+}
+
+// paletteSpreads returns for every palette color the amount of noise
+// ordered dithering may add to pixels nearest to it.
+//
+// The noise is added to all channels, so it's limited by the distance to
+// the nearest other color divided by sqrt(3). A pixel, which already has
+// a palette color, then never moves past the midpoint to another color.
+func paletteSpreads(palette color.Palette) []float64 {
+	spreads := make([]float64, len(palette))
+	for i, a := range palette {
+		ar, ag, ab, aa := a.RGBA()
+		if aa == 0 {
+			continue
+		}
+
+		nearest := math.Inf(1)
+		for k, b := range palette {
+			br, bg, bb, ba := b.RGBA()
+			if k == i || ba == 0 {
+				continue
+			}
+			dr := float64(ar>>8) - float64(br>>8)
+			dg := float64(ag>>8) - float64(bg>>8)
+			db := float64(ab>>8) - float64(bb>>8)
+			nearest = math.Min(nearest, math.Sqrt(dr*dr+dg*dg+db*db))
+		}
+		if !math.IsInf(nearest, 1) {
+			spreads[i] = nearest / math.Sqrt(3)
+		}
+	}
+	return spreads
+}
+
+func clampf(v float64) uint8 {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v + 0.5)
+}
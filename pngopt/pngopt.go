@@ -0,0 +1,482 @@
+// Package pngopt implements a lossless PNG encoder that searches for the
+// smallest encoding of an image.
+//
+// It picks the color type and bit depth from the image content, tries
+// several filter strategies and compression levels, drops all ancillary
+// chunks and verifies that the result decodes to identical pixels.
+package pngopt
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"sort"
+)
+
+// Options configures the search for the smallest encoding.
+type Options struct {
+	// Levels lists zlib compression levels to try.
+	Levels []int
+	// Filters lists filter strategies to try.
+	Filters []Filter
+}
+
+// DefaultOptions tries every filter strategy with the best compression.
+var DefaultOptions = Options{
+	Levels:  []int{zlib.BestCompression},
+	Filters: []Filter{FilterNone, FilterSub, FilterUp, FilterAverage, FilterPaeth, FilterAdaptive},
+}
+
+// Filter is a PNG row filter strategy.
+type Filter byte
+
+const (
+	FilterNone Filter = iota
+	FilterSub
+	FilterUp
+	FilterAverage
+	FilterPaeth
+
+	// FilterAdaptive picks the filter per row, which minimizes
+	// the sum of absolute differences.
+	FilterAdaptive Filter = 0xff
+)
+
+// Encode writes m to w using the smallest encoding found with DefaultOptions.
+func Encode(w io.Writer, m image.Image) error {
+	data, err := DefaultOptions.Encode(m)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// Encode returns the smallest encoding of m.
+func (opts *Options) Encode(m image.Image) ([]byte, error) {
+	pixels := nrgba64Pixels(m)
+	b := m.Bounds()
+
+	var best []byte
+	for _, format := range formats(pixels) {
+		for _, level := range opts.Levels {
+			for _, filter := range opts.Filters {
+				data, err := encode(b.Dx(), b.Dy(), pixels, format, filter, level)
+				if err != nil {
+					return nil, err
+				}
+				if best == nil || len(data) < len(best) {
+					best = data
+				}
+			}
+		}
+	}
+	if best == nil {
+		return nil, errors.New("pngopt: no encoding found")
+	}
+
+	if err := verify(best, pixels); err != nil {
+		return nil, err
+	}
+	return best, nil
+}
+
+// Optimize re-encodes PNG data, returning the original data when it
+// cannot be made smaller.
+func Optimize(data []byte) ([]byte, error) {
+	m, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	optimized, err := DefaultOptions.Encode(m)
+	if err != nil {
+		return nil, err
+	}
+	if len(optimized) >= len(data) {
+		return data, nil
+	}
+	return optimized, nil
+}
+
+// verify checks that data decodes to exactly the same pixels.
+func verify(data []byte, pixels []color.NRGBA64) error {
+	m, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("pngopt: verification failed: %v", err)
+	}
+
+	decoded := nrgba64Pixels(m)
+	if len(decoded) != len(pixels) {
+		return errors.New("pngopt: verification failed: size mismatch")
+	}
+	for i := range pixels {
+		if decoded[i] != pixels[i] {
+			return fmt.Errorf("pngopt: verification failed: pixel %d is %v, expected %v", i, decoded[i], pixels[i])
+		}
+	}
+	return nil
+}
+
+const (
+	colorGray      = 0
+	colorRGB       = 2
+	colorPalette   = 3
+	colorGrayAlpha = 4
+	colorRGBA      = 6
+)
+
+// format is a PNG color type and bit depth.
+type format struct {
+	ColorType byte
+	Depth     byte
+	Palette   []color.NRGBA64
+}
+
+// formats returns the lossless formats for pixels, smallest first.
+func formats(pixels []color.NRGBA64) []format {
+	opaque, gray, is8bit := true, true, true
+	colors := map[color.NRGBA64]struct{}{}
+	for _, c := range pixels {
+		opaque = opaque && c.A == 0xffff
+		gray = gray && c.R == c.G && c.G == c.B
+		is8bit = is8bit && c.R>>8 == c.R&0xff && c.G>>8 == c.G&0xff && c.B>>8 == c.B&0xff && c.A>>8 == c.A&0xff
+		if len(colors) <= 256 {
+			colors[c] = struct{}{}
+		}
+	}
+
+	depth := byte(8)
+	if !is8bit {
+		depth = 16
+	}
+
+	var result []format
+	if is8bit && len(colors) <= 256 {
+		palette := make([]color.NRGBA64, 0, len(colors))
+		for c := range colors {
+			palette = append(palette, c)
+		}
+		// transparent entries first keeps the tRNS chunk short
+		sort.Slice(palette, func(i, k int) bool {
+			a, b := palette[i], palette[k]
+			if (a.A == 0xffff) != (b.A == 0xffff) {
+				return a.A != 0xffff
+			}
+			if a.A != b.A {
+				return a.A < b.A
+			}
+			if a.R != b.R {
+				return a.R < b.R
+			}
+			if a.G != b.G {
+				return a.G < b.G
+			}
+			return a.B < b.B
+		})
+
+		paletteDepth := byte(8)
+		switch {
+		case len(palette) <= 2:
+			paletteDepth = 1
+		case len(palette) <= 4:
+			paletteDepth = 2
+		case len(palette) <= 16:
+			paletteDepth = 4
+		}
+		result = append(result, format{ColorType: colorPalette, Depth: paletteDepth, Palette: palette})
+	}
+
+	switch {
+	case gray && opaque:
+		grayDepth := depth
+		if is8bit {
+			grayDepth = minGrayDepth(pixels)
+		}
+		result = append(result, format{ColorType: colorGray, Depth: grayDepth})
+	case gray:
+		result = append(result, format{ColorType: colorGrayAlpha, Depth: depth})
+	case opaque:
+		result = append(result, format{ColorType: colorRGB, Depth: depth})
+	default:
+		result = append(result, format{ColorType: colorRGBA, Depth: depth})
+	}
+
+	return result
+}
+
+// minGrayDepth returns the smallest bit depth that can represent every gray level exactly.
+func minGrayDepth(pixels []color.NRGBA64) byte {
+	for _, depth := range []byte{1, 2, 4} {
+		step := 255 / (1<<depth - 1)
+		exact := true
+		for _, c := range pixels {
+			if int(c.R>>8)%step != 0 {
+				exact = false
+				break
+			}
+		}
+		if exact {
+			return depth
+		}
+	}
+	return 8
+}
+
+func encode(width, height int, pixels []color.NRGBA64, f format, filter Filter, level int) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+
+	header := make([]byte, 13)
+	binary.BigEndian.PutUint32(header[0:], uint32(width))
+	binary.BigEndian.PutUint32(header[4:], uint32(height))
+	header[8] = f.Depth
+	header[9] = f.ColorType
+	writeChunk(&buf, "IHDR", header)
+
+	var index map[color.NRGBA64]byte
+	if f.ColorType == colorPalette {
+		index = map[color.NRGBA64]byte{}
+		plte := make([]byte, 0, 3*len(f.Palette))
+		trns := []byte{}
+		for i, c := range f.Palette {
+			index[c] = byte(i)
+			plte = append(plte, byte(c.R>>8), byte(c.G>>8), byte(c.B>>8))
+			if c.A != 0xffff {
+				trns = append(trns, byte(c.A>>8))
+			}
+		}
+		writeChunk(&buf, "PLTE", plte)
+		if len(trns) > 0 {
+			writeChunk(&buf, "tRNS", trns)
+		}
+	}
+
+	rows := packRows(width, height, pixels, f, index)
+
+	var compressed bytes.Buffer
+	zw, err := zlib.NewWriterLevel(&compressed, level)
+	if err != nil {
+		return nil, err
+	}
+	bw := bufio.NewWriter(zw)
+
+	bpp := bytesPerPixel(f)
+	var previous []byte
+	filtered := make([][]byte, 5)
+	for _, row := range rows {
+		if previous == nil {
+			previous = make([]byte, len(row))
+		}
+
+		var line []byte
+		if filter == FilterAdaptive {
+			best := -1
+			for ft := FilterNone; ft <= FilterPaeth; ft++ {
+				filtered[ft] = applyFilter(filtered[ft][:0], ft, row, previous, bpp)
+				if sum := absSum(filtered[ft]); best < 0 || sum < best {
+					best = sum
+					line = filtered[ft]
+				}
+			}
+		} else {
+			line = applyFilter(filtered[0][:0], filter, row, previous, bpp)
+			filtered[0] = line
+		}
+
+		if _, err := bw.Write(line); err != nil {
+			return nil, err
+		}
+		previous = row
+	}
+
+	if err := bw.Flush(); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	writeChunk(&buf, "IDAT", compressed.Bytes())
+	writeChunk(&buf, "IEND", nil)
+
+	return buf.Bytes(), nil
+}
+
+func bytesPerPixel(f format) int {
+	channels := map[byte]int{
+		colorGray:      1,
+		colorRGB:       3,
+		colorPalette:   1,
+		colorGrayAlpha: 2,
+		colorRGBA:      4,
+	}[f.ColorType]
+
+	bits := channels * int(f.Depth)
+	if bits < 8 {
+		return 1
+	}
+	return bits / 8
+}
+
+// packRows converts pixels to unfiltered scanlines.
+func packRows(width, height int, pixels []color.NRGBA64, f format, index map[color.NRGBA64]byte) [][]byte {
+	rows := make([][]byte, height)
+	for y := range rows {
+		line := pixels[y*width : (y+1)*width]
+
+		if f.Depth < 8 {
+			row := make([]byte, (width*int(f.Depth)+7)/8)
+			for x, c := range line {
+				var v byte
+				if f.ColorType == colorPalette {
+					v = index[c]
+				} else {
+					v = byte(c.R>>8) / byte(255/(1<<f.Depth-1))
+				}
+				bit := x * int(f.Depth)
+				row[bit/8] |= v << uint(8-int(f.Depth)-bit%8)
+			}
+			rows[y] = row
+			continue
+		}
+
+		row := make([]byte, 0, width*8)
+		put := func(v uint16) {
+			if f.Depth == 16 {
+				row = append(row, byte(v>>8), byte(v))
+			} else {
+				row = append(row, byte(v>>8))
+			}
+		}
+		for _, c := range line {
+			switch f.ColorType {
+			case colorPalette:
+				row = append(row, index[c])
+			case colorGray:
+				put(c.R)
+			case colorGrayAlpha:
+				put(c.R)
+				put(c.A)
+			case colorRGB:
+				put(c.R)
+				put(c.G)
+				put(c.B)
+			case colorRGBA:
+				put(c.R)
+				put(c.G)
+				put(c.B)
+				put(c.A)
+			}
+		}
+		rows[y] = row
+	}
+	return rows
+}
+
+// applyFilter appends filter type and the filtered row to dst.
+func applyFilter(dst []byte, filter Filter, row, previous []byte, bpp int) []byte {
+	dst = append(dst, byte(filter))
+	for i, x := range row {
+		var a, b, c byte
+		if i >= bpp {
+			a = row[i-bpp]
+			c = previous[i-bpp]
+		}
+		b = previous[i]
+
+		switch filter {
+		case FilterNone:
+			dst = append(dst, x)
+		case FilterSub:
+			dst = append(dst, x-a)
+		case FilterUp:
+			dst = append(dst, x-b)
+		case FilterAverage:
+			dst = append(dst, x-byte((int(a)+int(b))/2))
+		case FilterPaeth:
+			dst = append(dst, x-paeth(a, b, c))
+		}
+	}
+	return dst
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
+
+func absSum(line []byte) int {
+	sum := 0
+	for _, v := range line[1:] {
+		sum += abs(int(int8(v)))
+	}
+	return sum
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func writeChunk(w *bytes.Buffer, name string, data []byte) {
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(len(data)))
+	copy(header[4:], name)
+	w.Write(header[:])
+	w.Write(data)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+	w.Write(sum[:])
+}
+
+// nrgba64Pixels returns the non-premultiplied pixels of m in row order,
+// keeping the color of fully transparent pixels where m stores it.
+func nrgba64Pixels(m image.Image) []color.NRGBA64 {
+	b := m.Bounds()
+	pixels := make([]color.NRGBA64, 0, b.Dx()*b.Dy())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			pixels = append(pixels, nrgba64(m.At(x, y)))
+		}
+	}
+	return pixels
+}
+
+func nrgba64(c color.Color) color.NRGBA64 {
+	switch c := c.(type) {
+	case color.NRGBA:
+		return color.NRGBA64{
+			R: uint16(c.R) * 0x101, G: uint16(c.G) * 0x101,
+			B: uint16(c.B) * 0x101, A: uint16(c.A) * 0x101,
+		}
+	case color.NRGBA64:
+		return c
+	case color.RGBA:
+		// stay within 8 bits, like image/png does for premultiplied images
+		return nrgba64(color.NRGBAModel.Convert(c))
+	}
+	return color.NRGBA64Model.Convert(c).(color.NRGBA64)
+}
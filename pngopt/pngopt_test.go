@@ -0,0 +1,133 @@
+package pngopt
+
+import (
+	"bytes"
+	"compress/zlib"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+// testImages returns images covering every color type and bit depth.
+func testImages() map[string]image.Image {
+	images := map[string]image.Image{}
+
+	gray1 := image.NewGray(image.Rect(0, 0, 13, 7))
+	gray4 := image.NewGray(image.Rect(0, 0, 13, 7))
+	for y := 0; y < 7; y++ {
+		for x := 0; x < 13; x++ {
+			gray1.SetGray(x, y, color.Gray{uint8((x + y) % 2 * 255)})
+			gray4.SetGray(x, y, color.Gray{uint8((x * y) % 16 * 17)})
+		}
+	}
+	images["gray1"], images["gray4"] = gray1, gray4
+
+	gray8 := image.NewGray(image.Rect(0, 0, 300, 3))
+	rgb := image.NewNRGBA(image.Rect(0, 0, 31, 17))
+	rgba := image.NewNRGBA(image.Rect(0, 0, 31, 17))
+	grayAlpha := image.NewNRGBA(image.Rect(0, 0, 31, 17))
+	deep := image.NewNRGBA64(image.Rect(0, 0, 9, 5))
+	for y := 0; y < 17; y++ {
+		for x := 0; x < 31; x++ {
+			rgb.SetNRGBA(x, y, color.NRGBA{uint8(x * 8), uint8(y * 15), uint8(x * y), 0xff})
+			rgba.SetNRGBA(x, y, color.NRGBA{uint8(x * 8), uint8(y * 15), 0x80, uint8(x * y)})
+			grayAlpha.SetNRGBA(x, y, color.NRGBA{uint8(x * 8), uint8(x * 8), uint8(x * 8), uint8(y * 15)})
+			deep.SetNRGBA64(x%9, y%5, color.NRGBA64{uint16(x * 2001), uint16(y * 3001), 0x1234, 0xffff - uint16(x*y)})
+		}
+	}
+	for x := 0; x < 300; x++ {
+		gray8.SetGray(x, x%3, color.Gray{uint8(x)})
+	}
+	images["gray8"], images["rgb"], images["rgba"] = gray8, rgb, rgba
+	images["gray-alpha"], images["16bit"] = grayAlpha, deep
+
+	paletted := image.NewPaletted(image.Rect(0, 0, 64, 64), color.Palette{
+		color.NRGBA{}, color.NRGBA{0xff, 0, 0, 0x80}, color.NRGBA{0, 0xff, 0, 0xff},
+		color.NRGBA{0, 0, 0xff, 0xff}, color.NRGBA{0xff, 0xff, 0xff, 0xff},
+	})
+	for i := range paletted.Pix {
+		paletted.Pix[i] = uint8((i/3 + i/64) % 5)
+	}
+	images["paletted"] = paletted
+
+	return images
+}
+
+func TestEncodeDecodes(t *testing.T) {
+	for name, m := range testImages() {
+		pixels := nrgba64Pixels(m)
+		b := m.Bounds()
+		for _, f := range formats(pixels) {
+			for _, filter := range DefaultOptions.Filters {
+				data, err := encode(b.Dx(), b.Dy(), pixels, f, filter, zlib.DefaultCompression)
+				if err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				if err := verify(data, pixels); err != nil {
+					t.Errorf("%s: color type %d, depth %d, filter %d: %v", name, f.ColorType, f.Depth, filter, err)
+				}
+			}
+		}
+	}
+}
+
+func TestFormats(t *testing.T) {
+	tests := []struct {
+		name  string
+		color byte
+		depth byte
+	}{
+		// gray needs no palette
+		{"gray1", colorGray, 1},
+		{"gray4", colorGray, 4},
+		{"gray8", colorGray, 8},
+		{"rgb", colorRGB, 8},
+		{"rgba", colorRGBA, 8},
+		{"gray-alpha", colorGrayAlpha, 8},
+		{"16bit", colorRGBA, 16},
+		{"paletted", colorPalette, 4},
+	}
+	images := testImages()
+	for _, test := range tests {
+		data, err := DefaultOptions.Encode(images[test.name])
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		// IHDR is the first chunk, after the signature, length and type
+		depth, color := data[8+8+8], data[8+8+9]
+		if color != test.color || depth != test.depth {
+			t.Errorf("%s: got color type %d with depth %d, want %d with %d", test.name, color, depth, test.color, test.depth)
+		}
+	}
+}
+
+func TestOptimize(t *testing.T) {
+	m := testImages()["rgba"]
+
+	var buf bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.NoCompression}
+	if err := encoder.Encode(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	original := buf.Bytes()
+
+	optimized, err := Optimize(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(optimized) >= len(original) {
+		t.Errorf("optimized %d bytes to %d", len(original), len(optimized))
+	}
+	if err := verify(optimized, nrgba64Pixels(m)); err != nil {
+		t.Error(err)
+	}
+
+	again, err := Optimize(optimized)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again, optimized) {
+		t.Errorf("optimizing twice changed %d bytes to %d", len(optimized), len(again))
+	}
+}
@@ -0,0 +1,168 @@
+// recolor-svg rewrites the colors of fills, strokes and gradient stops.
+//
+//	go run recolor-svg.go -map "gopher blue=#ff8800" in.svg out.svg
+//	go run recolor-svg.go -map "#96d6ff=go fuchsia" -tolerance 40 in.svg out.svg
+//	go run recolor-svg.go -hue 120 in.svg out.svg
+//	go run recolor-svg.go -list
+//
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/egonelbre/gophers/internal/atomicfile"
+	"github.com/egonelbre/gophers/svg"
+)
+
+var (
+	mapping   = flag.String("map", "", "comma separated color replacements from=to, colors are hex or palette names")
+	hue       = flag.Float64("hue", 0, "shift the hue of colors not replaced by -map in degrees")
+	tolerance = flag.Float64("tolerance", 0, "also replace colors within this RGB distance, keeping their difference to the source")
+	list      = flag.Bool("list", false, "list palette names")
+)
+
+// palette contains the named colors, which can be used in -map.
+var palette = map[string]string{
+	// colors used in the drawings
+	"gopher blue":    "#96d6ff",
+	"gopher outline": "#394655",
+	"gopher muzzle":  "#e1d6b9",
+
+	// colors from the Go brand book
+	"go blue":       "#00add8",
+	"go light blue": "#5dc9e2",
+	"go aqua":       "#00a29c",
+	"go teal":       "#007d9c",
+	"go fuchsia":    "#ce3262",
+	"go yellow":     "#fddd00",
+	"go black":      "#000000",
+}
+
+// Replacement replaces a single color.
+type Replacement struct {
+	From, To color.NRGBA
+}
+
+func main() {
+	flag.Parse()
+
+	if *list {
+		names := make([]string, 0, len(palette))
+		for name := range palette {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("%-16s %s\n", name, palette[name])
+		}
+		return
+	}
+
+	if flag.Arg(0) == "" || flag.Arg(1) == "" || (*mapping == "" && *hue == 0) {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	replacements, err := ParseMapping(*mapping)
+	check(err)
+
+	data, err := ioutil.ReadFile(flag.Arg(0))
+	check(err)
+	doc, err := svg.ParseString(string(data))
+	check(err)
+
+	count := doc.MapColors(func(c color.NRGBA) (color.NRGBA, bool) {
+		return Recolor(c, replacements, *tolerance, *hue)
+	})
+	fmt.Printf("%s: replaced %d colors\n", flag.Arg(0), count)
+
+	check(atomicfile.WriteFile(flag.Arg(1), doc.Bytes()))
+}
+
+// ParseMapping parses replacements of the form "from=to,from=to".
+func ParseMapping(s string) ([]Replacement, error) {
+	var replacements []Replacement
+	for _, part := range strings.Split(s, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		i := strings.IndexByte(part, '=')
+		if i < 0 {
+			return nil, fmt.Errorf("invalid mapping %q, expected from=to", part)
+		}
+		from, err := LookupColor(part[:i])
+		if err != nil {
+			return nil, err
+		}
+		to, err := LookupColor(part[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		replacements = append(replacements, Replacement{From: from, To: to})
+	}
+	return replacements, nil
+}
+
+// LookupColor parses a palette name or a color.
+func LookupColor(s string) (color.NRGBA, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if hex, ok := palette[s]; ok {
+		s = hex
+	}
+	c, ok := svg.ParseColor(s)
+	if !ok {
+		return c, fmt.Errorf("unknown color %q, see -list", s)
+	}
+	return c, nil
+}
+
+// Recolor finds the closest replacement within tolerance, colors without
+// a replacement have their hue shifted.
+func Recolor(c color.NRGBA, replacements []Replacement, tolerance, hue float64) (color.NRGBA, bool) {
+	best, bestDist := -1, tolerance
+	for i, r := range replacements {
+		if dist := svg.ColorDistance(c, r.From); dist <= bestDist {
+			best, bestDist = i, dist
+		}
+	}
+
+	if best >= 0 {
+		r := replacements[best]
+		// keep the difference to the source, so shading is preserved
+		shift := func(v, from, to uint8) uint8 {
+			x := int(v) - int(from) + int(to)
+			if x < 0 {
+				return 0
+			}
+			if x > 0xff {
+				return 0xff
+			}
+			return uint8(x)
+		}
+		return color.NRGBA{
+			R: shift(c.R, r.From.R, r.To.R),
+			G: shift(c.G, r.From.G, r.To.G),
+			B: shift(c.B, r.From.B, r.To.B),
+			A: c.A,
+		}, true
+	}
+
+	if hue != 0 {
+		return svg.ShiftHue(c, hue), true
+	}
+	return c, false
+}
+
+func check(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,77 @@
+// remap-palette converts images to a palette or extracts their palette.
+//
+//	go run remap-palette.go -palette animation/palette.ase -dither bayer4 in.png out.png
+//	go run remap-palette.go -extract 16 in.png palette.gpl
+//
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"strings"
+
+	_ "image/jpeg"
+	_ "image/png"
+
+	"github.com/egonelbre/gophers/internal/atomicfile"
+	"github.com/egonelbre/gophers/palette"
+	"github.com/egonelbre/gophers/pngopt"
+)
+
+var (
+	palettePath = flag.String("palette", "animation/palette.ase", "palette file: .ase, .aseprite, .gpl, .pal or an image")
+	dither      = flag.String("dither", "none", "dithering: none, floyd-steinberg, bayer2, bayer4 or bayer8")
+	extract     = flag.Int("extract", 0, "extract at most N colors from the input and write them as a GIMP palette")
+)
+
+func main() {
+	flag.Parse()
+
+	if flag.Arg(0) == "" || flag.Arg(1) == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	infile, err := os.Open(flag.Arg(0))
+	check(err)
+	source, _, err := image.Decode(infile)
+	infile.Close()
+	check(err)
+
+	var output bytes.Buffer
+	if *extract > 0 {
+		name := filepath.Base(flag.Arg(0))
+		check(palette.WriteGPL(&output, name, palette.Extract(source, *extract)))
+		check(atomicfile.WriteFile(flag.Arg(1), output.Bytes()))
+		return
+	}
+
+	pal, err := palette.Load(*palettePath)
+	check(err)
+
+	mode, ok := palette.ParseDither(*dither)
+	if !ok {
+		check(fmt.Errorf("unknown dither %q", *dither))
+	}
+
+	target := palette.Remap(source, pal, mode)
+	if strings.ToLower(filepath.Ext(flag.Arg(1))) == ".gif" {
+		check(gif.Encode(&output, target, nil))
+	} else {
+		check(pngopt.Encode(&output, target))
+	}
+	check(atomicfile.WriteFile(flag.Arg(1), output.Bytes()))
+}
+
+func check(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,299 @@
+// Package scale implements upscalers suitable for pixel art.
+//
+// Unlike the interpolating scalers in golang.org/x/image/draw these
+// only reuse colors of the source, so edges stay sharp and the palette
+// is preserved.
+package scale
+
+import (
+	"image"
+	"image/color"
+
+	"golang.org/x/image/draw"
+)
+
+// MaxPixelArtSize is the largest dimension of an image considered pixel art.
+const MaxPixelArtSize = 256
+
+// IsPixelArt reports whether m is a small indexed image,
+// either paletted or with at most 256 colors.
+func IsPixelArt(m image.Image) bool {
+	b := m.Bounds()
+	if b.Dx() > MaxPixelArtSize || b.Dy() > MaxPixelArtSize {
+		return false
+	}
+	if _, ok := m.(*image.Paletted); ok {
+		return true
+	}
+
+	colors := map[color.Color]struct{}{}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			colors[m.At(x, y)] = struct{}{}
+			if len(colors) > 256 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Interpolator returns the scaler to use for m,
+// nearest-neighbor for pixel art and Catmull-Rom otherwise.
+func Interpolator(m image.Image) draw.Interpolator {
+	if IsPixelArt(m) {
+		return draw.NearestNeighbor
+	}
+	return draw.CatmullRom
+}
+
+// grid is an image as a flat slice of colors with clamped access.
+type grid struct {
+	w, h int
+	pix  []color.NRGBA
+}
+
+func newGrid(m image.Image) *grid {
+	b := m.Bounds()
+	g := &grid{w: b.Dx(), h: b.Dy()}
+	g.pix = make([]color.NRGBA, 0, g.w*g.h)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			g.pix = append(g.pix, color.NRGBAModel.Convert(m.At(x, y)).(color.NRGBA))
+		}
+	}
+	return g
+}
+
+// at returns the color at x, y, clamping coordinates to the image.
+func (g *grid) at(x, y int) color.NRGBA {
+	if x < 0 {
+		x = 0
+	} else if x >= g.w {
+		x = g.w - 1
+	}
+	if y < 0 {
+		y = 0
+	} else if y >= g.h {
+		y = g.h - 1
+	}
+	return g.pix[y*g.w+x]
+}
+
+// Nearest scales m up by an integer factor using nearest-neighbor.
+func Nearest(m image.Image, factor int) *image.NRGBA {
+	if factor < 1 {
+		factor = 1
+	}
+	g := newGrid(m)
+	dst := image.NewNRGBA(image.Rect(0, 0, g.w*factor, g.h*factor))
+	for y := 0; y < g.h*factor; y++ {
+		for x := 0; x < g.w*factor; x++ {
+			dst.SetNRGBA(x, y, g.pix[(y/factor)*g.w+x/factor])
+		}
+	}
+	return dst
+}
+
+// Scale2x scales m by 2 using the Scale2x (AdvMAME2x) algorithm.
+func Scale2x(m image.Image) *image.NRGBA {
+	g := newGrid(m)
+	dst := image.NewNRGBA(image.Rect(0, 0, g.w*2, g.h*2))
+	for y := 0; y < g.h; y++ {
+		for x := 0; x < g.w; x++ {
+			//   B
+			// D E F
+			//   H
+			b, d, e, f, h := g.at(x, y-1), g.at(x-1, y), g.at(x, y), g.at(x+1, y), g.at(x, y+1)
+
+			e0, e1, e2, e3 := e, e, e, e
+			if b != h && d != f {
+				if d == b {
+					e0 = d
+				}
+				if b == f {
+					e1 = f
+				}
+				if d == h {
+					e2 = d
+				}
+				if h == f {
+					e3 = f
+				}
+			}
+
+			dst.SetNRGBA(2*x, 2*y, e0)
+			dst.SetNRGBA(2*x+1, 2*y, e1)
+			dst.SetNRGBA(2*x, 2*y+1, e2)
+			dst.SetNRGBA(2*x+1, 2*y+1, e3)
+		}
+	}
+	return dst
+}
+
+// Scale3x scales m by 3 using the Scale3x (AdvMAME3x) algorithm.
+func Scale3x(m image.Image) *image.NRGBA {
+	g := newGrid(m)
+	dst := image.NewNRGBA(image.Rect(0, 0, g.w*3, g.h*3))
+	for y := 0; y < g.h; y++ {
+		for x := 0; x < g.w; x++ {
+			// A B C
+			// D E F
+			// G H I
+			a, b, c := g.at(x-1, y-1), g.at(x, y-1), g.at(x+1, y-1)
+			d, e, f := g.at(x-1, y), g.at(x, y), g.at(x+1, y)
+			gg, h, i := g.at(x-1, y+1), g.at(x, y+1), g.at(x+1, y+1)
+
+			out := [9]color.NRGBA{e, e, e, e, e, e, e, e, e}
+			if b != h && d != f {
+				if d == b {
+					out[0] = d
+				}
+				if (d == b && e != c) || (b == f && e != a) {
+					out[1] = b
+				}
+				if b == f {
+					out[2] = f
+				}
+				if (d == b && e != gg) || (d == h && e != a) {
+					out[3] = d
+				}
+				if (b == f && e != i) || (h == f && e != c) {
+					out[5] = f
+				}
+				if d == h {
+					out[6] = d
+				}
+				if (d == h && e != i) || (h == f && e != gg) {
+					out[7] = h
+				}
+				if h == f {
+					out[8] = f
+				}
+			}
+
+			for k, c := range out {
+				dst.SetNRGBA(3*x+k%3, 3*y+k/3, c)
+			}
+		}
+	}
+	return dst
+}
+
+// EPX scales m by 2 using Eric's Pixel Expansion.
+func EPX(m image.Image) *image.NRGBA {
+	g := newGrid(m)
+	dst := image.NewNRGBA(image.Rect(0, 0, g.w*2, g.h*2))
+	for y := 0; y < g.h; y++ {
+		for x := 0; x < g.w; x++ {
+			//   A
+			// C P B
+			//   D
+			a, c, p, b, d := g.at(x, y-1), g.at(x-1, y), g.at(x, y), g.at(x+1, y), g.at(x, y+1)
+
+			p1, p2, p3, p4 := p, p, p, p
+			if c == a {
+				p1 = a
+			}
+			if a == b {
+				p2 = b
+			}
+			if d == c {
+				p3 = c
+			}
+			if b == d {
+				p4 = d
+			}
+
+			// three or more identical neighbours means
+			// we are inside an area and shouldn't change
+			same := 0
+			for _, q := range []color.NRGBA{b, c, d} {
+				if q == a {
+					same++
+				}
+			}
+			if same >= 2 || (b == c && c == d) {
+				p1, p2, p3, p4 = p, p, p, p
+			}
+
+			dst.SetNRGBA(2*x, 2*y, p1)
+			dst.SetNRGBA(2*x+1, 2*y, p2)
+			dst.SetNRGBA(2*x, 2*y+1, p3)
+			dst.SetNRGBA(2*x+1, 2*y+1, p4)
+		}
+	}
+	return dst
+}
+
+// XBR2x scales m by 2 using the edge detection rules of xBR level 1.
+// Detected edges are filled with a neighbouring color instead of
+// blending, so the result contains only colors of the source.
+func XBR2x(m image.Image) *image.NRGBA {
+	g := newGrid(m)
+	dst := image.NewNRGBA(image.Rect(0, 0, g.w*2, g.h*2))
+	for y := 0; y < g.h; y++ {
+		for x := 0; x < g.w; x++ {
+			for corner := 0; corner < 4; corner++ {
+				// rotate the neighbourhood so that the corner is bottom-right
+				at := func(dx, dy int) color.NRGBA {
+					for k := 0; k < corner; k++ {
+						dx, dy = -dy, dx
+					}
+					return g.at(x+dx, y+dy)
+				}
+
+				//    A1 B1 C1
+				// A0 A  B  C  C4
+				// D0 D  E  F  F4
+				// G0 G  H  I  I4
+				//    G5 H5 I5
+				b, c := at(0, -1), at(1, -1)
+				d, e, f := at(-1, 0), at(0, 0), at(1, 0)
+				gg, h, i := at(-1, 1), at(0, 1), at(1, 1)
+				f4, i4 := at(2, 0), at(2, 1)
+				h5, i5 := at(0, 2), at(1, 2)
+
+				out := e
+				if e != f && e != h {
+					edge := dist(e, c) + dist(e, gg) + dist(i, f4) + dist(i, h5) + 4*dist(h, f)
+					opposite := dist(h, d) + dist(h, i5) + dist(f, i4) + dist(f, b) + 4*dist(e, i)
+					if edge < opposite {
+						if dist(e, f) <= dist(e, h) {
+							out = f
+						} else {
+							out = h
+						}
+					}
+				}
+
+				// corner 0 is bottom-right, each rotation turns clockwise
+				offsets := [4]image.Point{{1, 1}, {0, 1}, {0, 0}, {1, 0}}
+				o := offsets[corner]
+				dst.SetNRGBA(2*x+o.X, 2*y+o.Y, out)
+			}
+		}
+	}
+	return dst
+}
+
+// dist is the distance of two colors in YUV space, as used by xBR.
+func dist(a, b color.NRGBA) int {
+	r := int(a.R) - int(b.R)
+	g := int(a.G) - int(b.G)
+	bl := int(a.B) - int(b.B)
+	al := int(a.A) - int(b.A)
+
+	y := abs(r*299+g*587+bl*114) / 1000
+	u := abs(-r*169-g*331+bl*500) / 1000
+	v := abs(r*500-g*419-bl*81) / 1000
+
+	return 48*y + 7*u + 6*v + 16*abs(al)
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,184 @@
+package scale
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+var (
+	black = color.NRGBA{0x00, 0x00, 0x00, 0xff}
+	white = color.NRGBA{0xff, 0xff, 0xff, 0xff}
+)
+
+// parse creates an image from rows of '#' for black and '.' for white.
+func parse(rows ...string) *image.NRGBA {
+	m := image.NewNRGBA(image.Rect(0, 0, len(rows[0]), len(rows)))
+	for y, row := range rows {
+		for x, c := range row {
+			if c == '#' {
+				m.SetNRGBA(x, y, black)
+			} else {
+				m.SetNRGBA(x, y, white)
+			}
+		}
+	}
+	return m
+}
+
+// format writes m as rows of '#' for black and '.' for white.
+func format(m *image.NRGBA) []string {
+	var rows []string
+	b := m.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		var row strings.Builder
+		for x := b.Min.X; x < b.Max.X; x++ {
+			switch m.NRGBAAt(x, y) {
+			case black:
+				row.WriteByte('#')
+			case white:
+				row.WriteByte('.')
+			default:
+				row.WriteByte('?')
+			}
+		}
+		rows = append(rows, row.String())
+	}
+	return rows
+}
+
+func TestScalers(t *testing.T) {
+	diagonal := parse(
+		"#...",
+		".#..",
+		"..#.",
+		"...#",
+	)
+
+	tests := []struct {
+		name  string
+		scale func(image.Image) *image.NRGBA
+		want  []string
+	}{
+		{"nearest", func(m image.Image) *image.NRGBA { return Nearest(m, 2) }, []string{
+			"##......",
+			"##......",
+			"..##....",
+			"..##....",
+			"....##..",
+			"....##..",
+			"......##",
+			"......##",
+		}},
+		{"scale2x", Scale2x, []string{
+			"##......",
+			"#.#.....",
+			".###....",
+			"..###...",
+			"...###..",
+			"....###.",
+			".....#.#",
+			"......##",
+		}},
+		{"scale3x", Scale3x, []string{
+			"###.........",
+			"##.#........",
+			"#..#........",
+			".#####......",
+			"...###......",
+			"...####.....",
+			".....####...",
+			"......###...",
+			"......#####.",
+			"........#..#",
+			"........#.##",
+			".........###",
+		}},
+		{"epx", EPX, []string{
+			"##......",
+			"#.#.....",
+			".###....",
+			"..###...",
+			"...###..",
+			"....###.",
+			".....#.#",
+			"......##",
+		}},
+		{"xbr2x", XBR2x, []string{
+			"##......",
+			"###.....",
+			".##.....",
+			"...##...",
+			"...##...",
+			".....##.",
+			".....###",
+			"......##",
+		}},
+	}
+	for _, test := range tests {
+		got := format(test.scale(diagonal))
+		if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+		}
+	}
+}
+
+func TestScalersKeepColors(t *testing.T) {
+	// a pattern with several colors and no regular structure
+	m := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	source := map[color.NRGBA]bool{}
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			v := uint8((x*x + 3*y + x*y) % 5 * 60)
+			c := color.NRGBA{v, 255 - v, v / 2, 0xff}
+			m.SetNRGBA(x, y, c)
+			source[c] = true
+		}
+	}
+
+	scalers := map[string]func(image.Image) *image.NRGBA{
+		"scale2x": Scale2x, "scale3x": Scale3x, "epx": EPX, "xbr2x": XBR2x,
+	}
+	for name, scale := range scalers {
+		got := scale(m)
+		b := got.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				if c := got.NRGBAAt(x, y); !source[c] {
+					t.Fatalf("%s: new color %v at %d,%d", name, c, x, y)
+				}
+			}
+		}
+	}
+
+	// EPX and Scale2x are the same algorithm
+	epx, scale2x := EPX(m), Scale2x(m)
+	for i := range epx.Pix {
+		if epx.Pix[i] != scale2x.Pix[i] {
+			t.Fatalf("EPX and Scale2x differ at byte %d", i)
+		}
+	}
+}
+
+func TestIsPixelArt(t *testing.T) {
+	small := parse("#.", ".#")
+	if !IsPixelArt(small) || Interpolator(small) == nil {
+		t.Errorf("two colors should be pixel art")
+	}
+
+	colorful := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			colorful.SetNRGBA(x, y, color.NRGBA{uint8(x * 8), uint8(y * 8), 0, 0xff})
+		}
+	}
+	if IsPixelArt(colorful) {
+		t.Errorf("%d colors shouldn't be pixel art", 32*32)
+	}
+
+	large := image.NewPaletted(image.Rect(0, 0, MaxPixelArtSize+1, 1), color.Palette{black})
+	if IsPixelArt(large) {
+		t.Errorf("images larger than %d shouldn't be pixel art", MaxPixelArtSize)
+	}
+}
@@ -0,0 +1,294 @@
+// split-svg splits a drawing with several poses into separate files,
+// each with a viewBox fitted to its contents.
+//
+//	go run split-svg.go vector/friends/crash-dummy.sheet.svg out
+//	go run split-svg.go -by layers drawing.svg out
+//	go run split-svg.go -by ids -ids sitting,side-view vector/friends/crash-dummy.sheet.svg out
+//
+// The output files are named after the group id or layer label,
+// prefixed with the name of the input file. Parts with text are skipped,
+// since text isn't measured, convert it with text-to-path first.
+//
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/egonelbre/gophers/internal/atomicfile"
+	"github.com/egonelbre/gophers/svg"
+)
+
+var (
+	by        = flag.String("by", "groups", "split by top-level \"groups\", inkscape \"layers\" or \"ids\"")
+	ids       = flag.String("ids", "", "comma separated element ids for -by ids")
+	prefix    = flag.String("prefix", "", "output file prefix, defaults to the input name")
+	margin    = flag.Float64("margin", 0, "margin around the contents")
+	precision = flag.Int("precision", 3, "decimals in the viewBox")
+)
+
+// graphics are the elements, which are rendered directly.
+var graphics = map[string]bool{
+	"g": true, "a": true, "switch": true, "svg": true, "use": true, "image": true,
+	"path": true, "rect": true, "circle": true, "ellipse": true, "line": true,
+	"polyline": true, "polygon": true, "text": true, "foreignObject": true, "flowRoot": true,
+}
+
+// resources are the elements, which are only rendered when referenced.
+var resources = map[string]bool{
+	"linearGradient": true, "radialGradient": true, "pattern": true,
+	"clipPath": true, "mask": true, "filter": true, "marker": true, "symbol": true,
+}
+
+func main() {
+	flag.Parse()
+
+	if flag.Arg(0) == "" || flag.Arg(1) == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	data, err := ioutil.ReadFile(flag.Arg(0))
+	check(err)
+	doc, err := svg.ParseString(string(data))
+	check(err)
+
+	parts, err := Parts(doc, *by, *ids)
+	check(err)
+	if len(parts) == 0 {
+		check(fmt.Errorf("%s: nothing to split by %s", flag.Arg(0), *by))
+	}
+
+	name := *prefix
+	if name == "" {
+		name = filepath.Base(flag.Arg(0))
+		name = strings.TrimSuffix(name, ".svg")
+		name = strings.TrimSuffix(name, ".sheet")
+		name += "-"
+	}
+
+	check(os.MkdirAll(flag.Arg(1), 0755))
+	for i, partName := range PartNames(parts) {
+		part, err := Extract(string(data), parts[i], *margin, *precision)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "skipping %s: %v\n", partName, err)
+			continue
+		}
+
+		outname := filepath.Join(flag.Arg(1), name+partName+".svg")
+		fmt.Println("Writing", outname)
+		check(atomicfile.WriteFile(outname, part.Bytes()))
+	}
+}
+
+// Parts finds the elements to split doc into.
+func Parts(doc *svg.Document, by, ids string) ([]*svg.Node, error) {
+	root := doc.Root()
+	if root == nil {
+		return nil, fmt.Errorf("document has no root element")
+	}
+
+	var parts []*svg.Node
+	switch by {
+	case "groups":
+		for _, child := range root.Elements() {
+			if child.Is("g") {
+				parts = append(parts, child)
+			}
+		}
+	case "layers":
+		root.Walk(func(node *svg.Node) bool {
+			if node.IsLayer() {
+				parts = append(parts, node)
+				// sublayers stay in their layer
+				return false
+			}
+			return true
+		})
+	case "ids":
+		index := doc.IDs()
+		for _, id := range strings.Split(ids, ",") {
+			id = strings.TrimSpace(id)
+			if id == "" {
+				continue
+			}
+			node := index[id]
+			if node == nil {
+				return nil, fmt.Errorf("no element with id %q", id)
+			}
+			parts = append(parts, node)
+		}
+	default:
+		return nil, fmt.Errorf("unknown split %q, expected groups, layers or ids", by)
+	}
+	return parts, nil
+}
+
+var rxUnsafe = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// PartNames returns unique file names for the parts, using
+// the layer label or id and falling back to the index.
+func PartNames(parts []*svg.Node) []string {
+	used := map[string]bool{}
+	names := make([]string, len(parts))
+	for i, part := range parts {
+		name := part.ID()
+		if part.IsLayer() && part.Get("inkscape:label") != "" {
+			name = part.Get("inkscape:label")
+		}
+		name = strings.Trim(rxUnsafe.ReplaceAllString(strings.ToLower(name), "-"), "-")
+		if name == "" {
+			name = "part" + strconv.Itoa(i+1)
+		}
+
+		unique := name
+		for k := 2; used[unique]; k++ {
+			unique = name + "-" + strconv.Itoa(k)
+		}
+		used[unique] = true
+		names[i] = unique
+	}
+	return names
+}
+
+// Extract creates a document from source, which only contains part
+// and the resources it uses, with the viewBox fitted to part.
+func Extract(source string, part *svg.Node, margin float64, precision int) (*svg.Document, error) {
+	// parsing again is the simplest way to copy the document
+	doc, err := svg.ParseString(source)
+	if err != nil {
+		return nil, err
+	}
+	target := follow(doc, locate(part))
+
+	// hidden layers are shown on their own, also inside hidden parents
+	for node := target; node != nil && node.Kind == svg.Element; node = node.Parent {
+		style := node.Style()
+		if display, ok := style.Get("display"); ok && display == "none" {
+			style.Remove("display")
+			node.SetStyle(style)
+		}
+		if node.Get("display") == "none" {
+			node.Remove("display")
+		}
+	}
+
+	for node := target; node.Parent != nil; node = node.Parent {
+		for _, sibling := range node.Parent.Elements() {
+			if sibling != node && graphics[svg.Local(sibling.Name)] {
+				sibling.Detach()
+			}
+		}
+	}
+	RemoveUnused(doc)
+
+	// bounds don't measure text, the viewBox would clip it
+	hasText := false
+	target.Walk(func(node *svg.Node) bool {
+		if node.Kind == svg.Element && (node.Is("text") || node.Is("flowRoot")) {
+			hasText = true
+		}
+		return !hasText
+	})
+	if hasText {
+		return nil, fmt.Errorf("contains text, convert it first with fix-svg-style -passes text-to-path -font")
+	}
+
+	box := doc.Bounds(target).Inset(-margin)
+	if box.Empty() {
+		return nil, fmt.Errorf("no visible geometry")
+	}
+	FitViewBox(doc.Root(), box, precision)
+	return doc, nil
+}
+
+// locate returns the child indices leading from the document to node.
+func locate(node *svg.Node) []int {
+	var path []int
+	for ; node.Parent != nil; node = node.Parent {
+		for i, child := range node.Parent.Children {
+			if child == node {
+				path = append([]int{i}, path...)
+				break
+			}
+		}
+	}
+	return path
+}
+
+// follow returns the node at the child indices starting from the root element.
+func follow(doc *svg.Document, path []int) *svg.Node {
+	node := doc.Root()
+	for _, i := range path {
+		node = node.Children[i]
+	}
+	return node
+}
+
+// RemoveUnused removes resources, which are not referenced anymore.
+func RemoveUnused(doc *svg.Document) {
+	// removing a resource may make the resources it references unused
+	for removed := true; removed; {
+		removed = false
+		refs := doc.References()
+		doc.Walk(func(node *svg.Node) bool {
+			if node.Kind == svg.Element && resources[svg.Local(node.Name)] && !refs[node.ID()] && node.Parent != nil {
+				node.Detach()
+				removed = true
+				return false
+			}
+			return true
+		})
+	}
+}
+
+// FitViewBox sets the viewBox of root to box, rounded outwards.
+// Absolute width and height are scaled to keep the size of the contents.
+func FitViewBox(root *svg.Node, box svg.Box, precision int) {
+	scale := math.Pow(10, float64(precision))
+	format := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	minX, minY := math.Floor(box.MinX*scale)/scale, math.Floor(box.MinY*scale)/scale
+	maxX, maxY := math.Ceil(box.MaxX*scale)/scale, math.Ceil(box.MaxY*scale)/scale
+	width, height := math.Round((maxX-minX)*scale)/scale, math.Round((maxY-minY)*scale)/scale
+
+	// the old viewBox determines how many pixels a unit is
+	old := strings.Fields(strings.Replace(root.Get("viewBox"), ",", " ", -1))
+	for i, name := range []string{"width", "height"} {
+		value := strings.TrimSpace(root.Get(name))
+		number := strings.TrimRight(value, "abcdefghijklmnopqrstuvwxyz%")
+		unit := value[len(number):]
+		size, err := strconv.ParseFloat(number, 64)
+		if err != nil || unit == "%" {
+			// relative sizes fit any viewBox
+			continue
+		}
+
+		units := size
+		if len(old) == 4 {
+			if v, err := strconv.ParseFloat(old[2+i], 64); err == nil && v > 0 {
+				units = v
+			}
+		}
+		content := []float64{width, height}[i] * size / units
+		root.Set(name, format(math.Round(content*scale)/scale)+unit)
+	}
+
+	root.Set("viewBox", format(minX)+" "+format(minY)+" "+format(width)+" "+format(height))
+}
+
+func check(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,244 @@
+// sprite-to-go generates Go source embedding a sprite sheet.
+//
+//	go run sprite-to-go.go -package gopher animation/2bit-sprite/sheet.png animation/2bit-sprite/sheet.json sheet.go
+//
+// The generated file contains the palette, packed pixel data, frame
+// rectangles and durations and constants for the animation tags,
+// which are named <name>Tag<tag>.
+// It only depends on the standard library.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	_ "image/gif"
+	_ "image/png"
+
+	"github.com/egonelbre/gophers/internal/atomicfile"
+	"github.com/egonelbre/gophers/palette"
+	"github.com/egonelbre/gophers/sprite"
+)
+
+var (
+	pkgname = flag.String("package", "sprites", "package name of the generated file")
+	name    = flag.String("name", "", "prefix for generated identifiers, defaults to the sheet file name")
+)
+
+// Identifier converts s into an exported Go identifier.
+func Identifier(s string) string {
+	var buf strings.Builder
+	upper := true
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		buf.WriteRune(r)
+	}
+
+	id := buf.String()
+	if id == "" || unicode.IsDigit(rune(id[0])) {
+		id = "S" + id
+	}
+	return id
+}
+
+// Paletted returns m as a paletted image, keeping the palette when possible.
+func Paletted(m image.Image) (*image.Paletted, error) {
+	if p, ok := m.(*image.Paletted); ok {
+		return p, nil
+	}
+
+	pal, err := palette.FromImage(m)
+	if err != nil {
+		return nil, err
+	}
+	return palette.Remap(m, pal, palette.None), nil
+}
+
+func Generate(sheet *image.Paletted, atlas *sprite.Atlas) ([]byte, error) {
+	prefix := *name
+	if prefix == "" {
+		prefix = Identifier(strings.TrimSuffix(filepath.Base(flag.Arg(0)), filepath.Ext(flag.Arg(0))))
+	}
+	prefix = Identifier(prefix)
+	private := strings.ToLower(prefix[:1]) + prefix[1:]
+
+	bits := 1
+	for len(sheet.Palette) > 1<<uint(bits) {
+		bits *= 2
+	}
+	b := sheet.Bounds()
+	data := sprite.Pack(sheet, bits, sprite.Rows)
+
+	var buf bytes.Buffer
+	p := func(format string, args ...interface{}) { fmt.Fprintf(&buf, format, args...) }
+
+	p("// Code generated by sprite-to-go.go; DO NOT EDIT.\n\n")
+	p("package %s\n\n", *pkgname)
+	p("import (\n\"image\"\n\"image/color\"\n\"sync\"\n)\n\n")
+
+	p("// %sPalette is the palette of the sprite sheet.\n", prefix)
+	p("var %sPalette = color.Palette{\n", prefix)
+	for _, c := range sheet.Palette {
+		n := color.NRGBAModel.Convert(c).(color.NRGBA)
+		p("color.NRGBA{0x%02x, 0x%02x, 0x%02x, 0x%02x},\n", n.R, n.G, n.B, n.A)
+	}
+	p("}\n\n")
+
+	p("// %sFrames are the frame rectangles in the sheet.\n", prefix)
+	p("var %sFrames = [...]image.Rectangle{\n", prefix)
+	for _, frame := range atlas.Frames {
+		// frames are relative to the top-left of the sheet, like the packed data
+		r := frame.Frame.Rectangle()
+		p("{image.Point{%d, %d}, image.Point{%d, %d}},\n", r.Min.X, r.Min.Y, r.Max.X, r.Max.Y)
+	}
+	p("}\n\n")
+
+	p("// %sDurations are the frame durations in milliseconds.\n", prefix)
+	p("var %sDurations = [...]int{", prefix)
+	for i, frame := range atlas.Frames {
+		if i > 0 {
+			p(", ")
+		}
+		p("%d", frame.Duration)
+	}
+	p("}\n\n")
+
+	if len(atlas.Meta.FrameTags) > 0 {
+		p("// %sTag is an animation in the sheet, From and To are inclusive frame indices.\n", prefix)
+		p("type %sTag struct {\nName string\nFrom, To int\nDirection string\n}\n\n", prefix)
+
+		p("// Indices of %sTags.\n", prefix)
+		p("const (\n")
+		seen := map[string]bool{}
+		ids := []string{}
+		for i, tag := range atlas.Meta.FrameTags {
+			// the infix keeps tags such as "Frame" apart from the other identifiers
+			id := prefix + "Tag" + Identifier(tag.Name)
+			for seen[id] {
+				id += "_"
+			}
+			seen[id] = true
+			ids = append(ids, id)
+			p("%s = %d\n", id, i)
+		}
+		p(")\n\n")
+
+		p("// %sTags are the animations in the sheet.\n", prefix)
+		p("var %sTags = [...]%sTag{\n", prefix, prefix)
+		for i, tag := range atlas.Meta.FrameTags {
+			p("%s: {%q, %d, %d, %q},\n", ids[i], tag.Name, tag.From, tag.To, tag.Direction)
+		}
+		p("}\n\n")
+	}
+
+	p("// %sPix contains palette indices packed with %d bits per pixel,\n", private, bits)
+	p("// rows start on a byte boundary and the leftmost pixel is in the high bits.\n")
+	p("const %sPix = %s\n\n", private, quoteBytes(data))
+
+	p("var (\n%sOnce sync.Once\n%sPaletted *image.Paletted\n)\n\n", private, private)
+
+	p("// %sImage returns the whole sheet, the image must not be modified.\n", prefix)
+	p("func %sImage() *image.Paletted {\n", prefix)
+	p("%sOnce.Do(func() {\n", private)
+	p("const width, height, bits = %d, %d, %d\n", b.Dx(), b.Dy(), bits)
+	p("const perByte, stride = 8 / bits, (width*bits + 7) / 8\n")
+	p("m := image.NewPaletted(image.Rect(0, 0, width, height), %sPalette)\n", prefix)
+	p("for y := 0; y < height; y++ {\n")
+	p("for x := 0; x < width; x++ {\n")
+	p("v := %sPix[y*stride+x/perByte]\n", private)
+	p("shift := uint(perByte-1-x%%perByte) * bits\n")
+	p("m.Pix[y*m.Stride+x] = v >> shift & (1<<bits - 1)\n")
+	p("}\n}\n")
+	p("%sPaletted = m\n", private)
+	p("})\n")
+	p("return %sPaletted\n", private)
+	p("}\n\n")
+
+	p("// %sFrame returns frame i of the sheet, the image must not be modified.\n", prefix)
+	p("func %sFrame(i int) *image.Paletted {\n", prefix)
+	p("return %sImage().SubImage(%sFrames[i]).(*image.Paletted)\n", prefix, prefix)
+	p("}\n")
+
+	return format.Source(buf.Bytes())
+}
+
+// quoteBytes formats data as a string literal split over several lines.
+func quoteBytes(data []byte) string {
+	if len(data) == 0 {
+		return `""`
+	}
+
+	var buf strings.Builder
+	for i := 0; i < len(data); i += 32 {
+		end := i + 32
+		if end > len(data) {
+			end = len(data)
+		}
+		if i > 0 {
+			buf.WriteString(" +\n\t")
+		}
+		buf.WriteByte('"')
+		for _, v := range data[i:end] {
+			fmt.Fprintf(&buf, "\\x%02x", v)
+		}
+		buf.WriteByte('"')
+	}
+	return buf.String()
+}
+
+func main() {
+	flag.Parse()
+
+	if flag.Arg(0) == "" || flag.Arg(1) == "" || flag.Arg(2) == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	infile, err := os.Open(flag.Arg(0))
+	check(err)
+	source, _, err := image.Decode(infile)
+	infile.Close()
+	check(err)
+
+	sheet, err := Paletted(source)
+	check(err)
+
+	atlasfile, err := os.Open(flag.Arg(1))
+	check(err)
+	atlas, err := sprite.ReadAtlas(atlasfile)
+	atlasfile.Close()
+	check(err)
+
+	for i, frame := range atlas.Frames {
+		if !frame.Frame.Rectangle().Add(sheet.Bounds().Min).In(sheet.Bounds()) {
+			check(fmt.Errorf("frame %d is outside of the sheet", i))
+		}
+	}
+
+	output, err := Generate(sheet, atlas)
+	check(err)
+	check(atomicfile.WriteFile(flag.Arg(2), output))
+}
+
+func check(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,82 @@
+package sprite
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+)
+
+// Atlas is the sprite sheet data exported by Aseprite,
+// in either json-array or json-hash format.
+type Atlas struct {
+	Frames []Frame
+	Meta   Meta
+}
+
+type Frame struct {
+	Filename string
+	Frame    Rect
+	Duration int
+}
+
+type Rect struct {
+	X, Y, W, H int
+}
+
+func (r Rect) Rectangle() image.Rectangle {
+	return image.Rect(r.X, r.Y, r.X+r.W, r.Y+r.H)
+}
+
+type Meta struct {
+	Image     string
+	Size      struct{ W, H int }
+	FrameTags []FrameTag
+}
+
+type FrameTag struct {
+	Name      string
+	From, To  int
+	Direction string
+}
+
+// ReadAtlas reads Aseprite sheet data.
+func ReadAtlas(r io.Reader) (*Atlas, error) {
+	var raw struct {
+		Frames json.RawMessage
+		Meta   Meta
+	}
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	atlas := &Atlas{Meta: raw.Meta}
+	frames := bytes.TrimSpace(raw.Frames)
+	if len(frames) > 0 && frames[0] == '[' {
+		if err := json.Unmarshal(frames, &atlas.Frames); err != nil {
+			return nil, err
+		}
+		return atlas, nil
+	}
+
+	// json-hash keeps frames in an object, decode it
+	// token by token to preserve the frame order
+	dec := json.NewDecoder(bytes.NewReader(frames))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, fmt.Errorf("invalid frames: %v", err)
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var frame Frame
+		if err := dec.Decode(&frame); err != nil {
+			return nil, err
+		}
+		frame.Filename, _ = tok.(string)
+		atlas.Frames = append(atlas.Frames, frame)
+	}
+	return atlas, nil
+}
@@ -0,0 +1,58 @@
+package sprite
+
+import (
+	"image"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadAtlas(t *testing.T) {
+	meta := `"meta": {
+		"image": "dance.png",
+		"size": {"w": 64, "h": 32},
+		"frameTags": [{"name": "Jump", "from": 0, "to": 1, "direction": "forward"}]
+	}`
+	array := `{"frames": [
+		{"filename": "dance 0.ase", "frame": {"x": 0, "y": 0, "w": 32, "h": 32}, "duration": 100},
+		{"filename": "dance 1.ase", "frame": {"x": 32, "y": 0, "w": 32, "h": 32}, "duration": 50}
+	], ` + meta + `}`
+	// the hash keys are not sorted, the order must be kept
+	hash := `{"frames": {
+		"dance 1.ase": {"frame": {"x": 32, "y": 0, "w": 32, "h": 32}, "duration": 50},
+		"dance 0.ase": {"frame": {"x": 0, "y": 0, "w": 32, "h": 32}, "duration": 100}
+	}, ` + meta + `}`
+
+	for name, src := range map[string]string{"array": array, "hash": hash} {
+		atlas, err := ReadAtlas(strings.NewReader(src))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if len(atlas.Frames) != 2 {
+			t.Fatalf("%s: got %d frames", name, len(atlas.Frames))
+		}
+		var second Frame
+		if name == "array" {
+			second = atlas.Frames[1]
+		} else {
+			second = atlas.Frames[0]
+		}
+		want := Frame{Filename: "dance 1.ase", Frame: Rect{32, 0, 32, 32}, Duration: 50}
+		if second != want {
+			t.Errorf("%s: got frame %+v, want %+v", name, second, want)
+		}
+		if r := second.Frame.Rectangle(); r != image.Rect(32, 0, 64, 32) {
+			t.Errorf("%s: got rectangle %v", name, r)
+		}
+
+		tags := []FrameTag{{Name: "Jump", From: 0, To: 1, Direction: "forward"}}
+		if atlas.Meta.Image != "dance.png" || atlas.Meta.Size.W != 64 || !reflect.DeepEqual(atlas.Meta.FrameTags, tags) {
+			t.Errorf("%s: got meta %+v", name, atlas.Meta)
+		}
+	}
+
+	if _, err := ReadAtlas(strings.NewReader(`{"frames": 1}`)); err == nil {
+		t.Errorf("expected an error for invalid frames")
+	}
+}
@@ -0,0 +1,73 @@
+package sprite
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+)
+
+// EncodeBMP writes m as an uncompressed BMP with bits per pixel,
+// bits must be 1, 2, 4 or 8. 2 bits per pixel is not supported by
+// every reader, but is common for small monochrome displays.
+func EncodeBMP(w io.Writer, m *image.Paletted, bits int) error {
+	switch bits {
+	case 1, 2, 4, 8:
+	default:
+		return errors.New("sprite: unsupported bmp bit depth")
+	}
+	if len(m.Palette) > 1<<uint(bits) {
+		return errors.New("sprite: palette does not fit bit depth")
+	}
+
+	b := m.Bounds()
+	rowSize := (b.Dx()*bits + 31) / 32 * 4
+	paletteSize := 4 << uint(bits)
+	offset := 14 + 40 + paletteSize
+	size := offset + rowSize*b.Dy()
+
+	header := make([]byte, offset)
+	le := binary.LittleEndian
+
+	// BITMAPFILEHEADER
+	copy(header[0:], "BM")
+	le.PutUint32(header[2:], uint32(size))
+	le.PutUint32(header[10:], uint32(offset))
+
+	// BITMAPINFOHEADER
+	info := header[14:]
+	le.PutUint32(info[0:], 40)
+	le.PutUint32(info[4:], uint32(b.Dx()))
+	le.PutUint32(info[8:], uint32(b.Dy()))
+	le.PutUint16(info[12:], 1)
+	le.PutUint16(info[14:], uint16(bits))
+	le.PutUint32(info[20:], uint32(rowSize*b.Dy()))
+	le.PutUint32(info[24:], 2835) // 72 dpi
+	le.PutUint32(info[28:], 2835)
+	le.PutUint32(info[32:], uint32(len(m.Palette)))
+
+	table := info[40:]
+	for i, c := range m.Palette {
+		n := color.NRGBAModel.Convert(c).(color.NRGBA)
+		table[i*4+0] = n.B
+		table[i*4+1] = n.G
+		table[i*4+2] = n.R
+	}
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+
+	// rows are stored bottom-up and padded to 4 bytes
+	packed := Pack(m, bits, Rows)
+	stride := (b.Dx()*bits + 7) / 8
+	row := make([]byte, rowSize)
+	for y := b.Dy() - 1; y >= 0; y-- {
+		copy(row, packed[y*stride:(y+1)*stride])
+		if _, err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
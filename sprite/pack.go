@@ -0,0 +1,55 @@
+// Package sprite converts sprites into compact formats for small displays.
+package sprite
+
+import (
+	"image"
+)
+
+// Layout is the order of pixels in packed data.
+type Layout int
+
+const (
+	// Rows packs pixels left to right, each row starting on a new byte,
+	// with the leftmost pixel in the most significant bits.
+	Rows Layout = iota
+	// Pages packs pixels top to bottom in pages of 8/bits rows,
+	// with the topmost pixel in the least significant bits,
+	// as used by SSD1306 and similar displays.
+	Pages
+)
+
+// Pack packs palette indices of m using bits per pixel,
+// bits must be 1, 2, 4 or 8.
+func Pack(m *image.Paletted, bits int, layout Layout) []byte {
+	b := m.Bounds()
+	perByte := 8 / bits
+	mask := byte(1<<uint(bits) - 1)
+
+	var data []byte
+	switch layout {
+	case Pages:
+		for y0 := b.Min.Y; y0 < b.Max.Y; y0 += perByte {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				var v byte
+				for k := 0; k < perByte && y0+k < b.Max.Y; k++ {
+					v |= (m.ColorIndexAt(x, y0+k) & mask) << uint(k*bits)
+				}
+				data = append(data, v)
+			}
+		}
+	default:
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x0 := b.Min.X; x0 < b.Max.X; x0 += perByte {
+				var v byte
+				for k := 0; k < perByte; k++ {
+					v <<= uint(bits)
+					if x0+k < b.Max.X {
+						v |= m.ColorIndexAt(x0+k, y) & mask
+					}
+				}
+				data = append(data, v)
+			}
+		}
+	}
+	return data
+}
@@ -0,0 +1,122 @@
+package sprite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/egonelbre/gophers/palette"
+)
+
+// paletted creates an image with the palette indices in rows.
+func paletted(colors int, rows ...[]uint8) *image.Paletted {
+	palette := make(color.Palette, colors)
+	for i := range palette {
+		v := uint8(i * 255 / (colors - 1))
+		palette[i] = color.NRGBA{v, v, v, 0xff}
+	}
+	m := image.NewPaletted(image.Rect(0, 0, len(rows[0]), len(rows)), palette)
+	for y, row := range rows {
+		copy(m.Pix[y*m.Stride:], row)
+	}
+	return m
+}
+
+func TestPack(t *testing.T) {
+	mono := paletted(2,
+		[]uint8{1, 0, 1, 1, 0, 0, 0, 1, 1, 1},
+		[]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 1},
+	)
+	gray := paletted(4,
+		[]uint8{0, 1, 2},
+		[]uint8{3, 0, 1},
+		[]uint8{2, 3, 0},
+	)
+
+	tests := []struct {
+		name   string
+		m      *image.Paletted
+		bits   int
+		layout Layout
+		want   []byte
+	}{
+		// rows are padded to whole bytes
+		{"mono rows", mono, 1, Rows, []byte{0xb1, 0xc0, 0x00, 0x40}},
+		{"mono pages", mono, 1, Pages, []byte{1, 0, 1, 1, 0, 0, 0, 1, 1, 3}},
+		{"gray rows", gray, 2, Rows, []byte{0x18, 0xc4, 0xb0}},
+		{"gray pages", gray, 2, Pages, []byte{0x2c, 0x31, 0x06}},
+		{"gray nibbles", gray, 4, Rows, []byte{0x01, 0x20, 0x30, 0x10, 0x23, 0x00}},
+		{"gray bytes", gray, 8, Rows, []byte{0, 1, 2, 3, 0, 1, 2, 3, 0}},
+	}
+	for _, test := range tests {
+		if got := Pack(test.m, test.bits, test.layout); !bytes.Equal(got, test.want) {
+			t.Errorf("%s: got % x, want % x", test.name, got, test.want)
+		}
+	}
+}
+
+func TestEncodeBMP(t *testing.T) {
+	m := paletted(2,
+		[]uint8{1, 0, 1},
+		[]uint8{0, 1, 0},
+		[]uint8{1, 1, 0},
+	)
+
+	var buf bytes.Buffer
+	if err := EncodeBMP(&buf, m, 1); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	le := binary.LittleEndian
+	// 14 byte file header, 40 byte info header, 2 colors and 3 rows of 4 bytes
+	if len(data) != 14+40+2*4+3*4 || string(data[:2]) != "BM" || le.Uint32(data[2:]) != uint32(len(data)) {
+		t.Fatalf("invalid file header % x", data[:14])
+	}
+	if offset := le.Uint32(data[10:]); offset != 14+40+2*4 {
+		t.Fatalf("pixels at %d", offset)
+	}
+	if w, h, bits := le.Uint32(data[18:]), le.Uint32(data[22:]), le.Uint16(data[28:]); w != 3 || h != 3 || bits != 1 {
+		t.Fatalf("got %dx%d with %d bits", w, h, bits)
+	}
+	if table := data[54:62]; !bytes.Equal(table, []byte{0, 0, 0, 0, 0xff, 0xff, 0xff, 0}) {
+		t.Errorf("got color table % x", table)
+	}
+
+	// rows are stored bottom-up
+	want := []byte{
+		0xc0, 0, 0, 0,
+		0x40, 0, 0, 0,
+		0xa0, 0, 0, 0,
+	}
+	if pixels := data[62:]; !bytes.Equal(pixels, want) {
+		t.Errorf("got pixels % x, want % x", pixels, want)
+	}
+
+	if err := EncodeBMP(&buf, m, 3); err == nil {
+		t.Errorf("expected an error for 3 bits")
+	}
+	if err := EncodeBMP(&buf, paletted(3, []uint8{0, 1, 2}), 1); err == nil {
+		t.Errorf("expected an error for 3 colors in 1 bit")
+	}
+}
+
+func TestPackDitheredTwoColors(t *testing.T) {
+	// a custom two color palette, as given to convert-sprite -colors
+	pal := color.Palette{color.NRGBA{0x0f, 0x38, 0x0f, 0xff}, color.NRGBA{0x9b, 0xbc, 0x0f, 0xff}}
+	for index, c := range pal {
+		m := image.NewNRGBA(image.Rect(0, 0, 16, 8))
+		draw.Draw(m, m.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+
+		for _, dither := range []palette.Dither{palette.Bayer2, palette.Bayer4, palette.Bayer8} {
+			packed := Pack(palette.Remap(m, pal, dither), 1, Rows)
+			want := bytes.Repeat([]byte{byte(0xff * index)}, 16)
+			if !bytes.Equal(packed, want) {
+				t.Errorf("flat color %d with dither %d: got % x", index, dither, packed)
+			}
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package svg
+
+import (
+	"math"
+	"strings"
+)
+
+// Bounds returns the exact bounding box of the path transformed by m.
+//
+// Unlike the box of the control points, curves only contribute their
+// extremes. Arcs are approximated with cubic curves, which deviate
+// less than 0.03% of the radius.
+func (path Path) Bounds(m Matrix) Box {
+	box := EmptyBox()
+	add := func(x, y float64) {
+		box = box.Add(m.Apply(x, y))
+	}
+	cubic := func(x0, y0, x1, y1, x2, y2, x3, y3 float64) {
+		// curves stay curves under affine transforms,
+		// so the extremes can be found after transforming
+		x0, y0 = m.Apply(x0, y0)
+		x1, y1 = m.Apply(x1, y1)
+		x2, y2 = m.Apply(x2, y2)
+		x3, y3 = m.Apply(x3, y3)
+		box = box.Add(x0, y0).Add(x3, y3)
+		for _, t := range cubicExtremes(x0, x1, x2, x3) {
+			box = box.Add(cubicAt(x0, x1, x2, x3, t), cubicAt(y0, y1, y2, y3, t))
+		}
+		for _, t := range cubicExtremes(y0, y1, y2, y3) {
+			box = box.Add(cubicAt(x0, x1, x2, x3, t), cubicAt(y0, y1, y2, y3, t))
+		}
+	}
+	quad := func(x0, y0, x1, y1, x2, y2 float64) {
+		// elevate to a cubic with the same shape
+		cubic(x0, y0,
+			x0+2.0/3*(x1-x0), y0+2.0/3*(y1-y0),
+			x2+2.0/3*(x1-x2), y2+2.0/3*(y1-y2),
+			x2, y2)
+	}
+
+	var cur, start, ctrl [2]float64
+	var last byte
+	for _, seg := range path {
+		a := seg.Args
+		next := cur
+		switch seg.Command {
+		case 'M':
+			next = [2]float64{a[0], a[1]}
+			start = next
+			// a lone moveto doesn't draw anything
+		case 'Z':
+			next = start
+			add(cur[0], cur[1])
+			add(start[0], start[1])
+		case 'L', 'H', 'V':
+			switch seg.Command {
+			case 'L':
+				next = [2]float64{a[0], a[1]}
+			case 'H':
+				next[0] = a[0]
+			case 'V':
+				next[1] = a[0]
+			}
+			add(cur[0], cur[1])
+			add(next[0], next[1])
+		case 'C', 'S':
+			c1 := cur
+			if seg.Command == 'S' {
+				if last == 'C' || last == 'S' {
+					c1 = [2]float64{2*cur[0] - ctrl[0], 2*cur[1] - ctrl[1]}
+				}
+				a = append([]float64{c1[0], c1[1]}, a...)
+			}
+			next = [2]float64{a[4], a[5]}
+			cubic(cur[0], cur[1], a[0], a[1], a[2], a[3], a[4], a[5])
+			ctrl = [2]float64{a[2], a[3]}
+		case 'Q', 'T':
+			if seg.Command == 'T' {
+				c := cur
+				if last == 'Q' || last == 'T' {
+					c = [2]float64{2*cur[0] - ctrl[0], 2*cur[1] - ctrl[1]}
+				}
+				a = append([]float64{c[0], c[1]}, a...)
+			}
+			next = [2]float64{a[2], a[3]}
+			quad(cur[0], cur[1], a[0], a[1], a[2], a[3])
+			ctrl = [2]float64{a[0], a[1]}
+		case 'A':
+			next = [2]float64{a[5], a[6]}
+			for _, c := range arcToCubics(cur[0], cur[1], a[0], a[1], a[2], a[3] != 0, a[4] != 0, a[5], a[6]) {
+				cubic(c[0], c[1], c[2], c[3], c[4], c[5], c[6], c[7])
+			}
+		}
+		cur = next
+		last = seg.Command
+	}
+	return box
+}
+
+// cubicAt evaluates a one dimensional cubic curve at t.
+func cubicAt(p0, p1, p2, p3, t float64) float64 {
+	s := 1 - t
+	return s*s*s*p0 + 3*s*s*t*p1 + 3*s*t*t*p2 + t*t*t*p3
+}
+
+// cubicExtremes returns the t in (0, 1), where the derivative
+// of a one dimensional cubic curve is zero.
+func cubicExtremes(p0, p1, p2, p3 float64) []float64 {
+	// derivative is a*t^2 + b*t + c
+	a := 3 * (-p0 + 3*p1 - 3*p2 + p3)
+	b := 6 * (p0 - 2*p1 + p2)
+	c := 3 * (p1 - p0)
+
+	var roots []float64
+	if math.Abs(a) < 1e-12 {
+		if math.Abs(b) > 1e-12 {
+			roots = append(roots, -c/b)
+		}
+	} else {
+		d := b*b - 4*a*c
+		if d >= 0 {
+			d = math.Sqrt(d)
+			roots = append(roots, (-b+d)/(2*a), (-b-d)/(2*a))
+		}
+	}
+
+	ts := roots[:0]
+	for _, t := range roots {
+		if t > 0 && t < 1 {
+			ts = append(ts, t)
+		}
+	}
+	return ts
+}
+
+// arcToCubics converts an elliptical arc to cubic curves, each returned
+// as [x0 y0 x1 y1 x2 y2 x3 y3], following the SVG implementation notes.
+func arcToCubics(x1, y1, rx, ry, angle float64, large, sweep bool, x2, y2 float64) [][8]float64 {
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if rx == 0 || ry == 0 || (x1 == x2 && y1 == y2) {
+		return [][8]float64{{x1, y1, x1, y1, x2, y2, x2, y2}}
+	}
+
+	sin, cos := math.Sincos(angle * math.Pi / 180)
+	dx, dy := (x1-x2)/2, (y1-y2)/2
+	px := cos*dx + sin*dy
+	py := -sin*dx + cos*dy
+
+	// scale up radii that are too small to reach the end point
+	if lambda := px*px/(rx*rx) + py*py/(ry*ry); lambda > 1 {
+		lambda = math.Sqrt(lambda)
+		rx, ry = rx*lambda, ry*lambda
+	}
+
+	num := rx*rx*ry*ry - rx*rx*py*py - ry*ry*px*px
+	den := rx*rx*py*py + ry*ry*px*px
+	k := math.Sqrt(math.Max(num, 0) / den)
+	if large == sweep {
+		k = -k
+	}
+	cx := k * rx * py / ry
+	cy := -k * ry * px / rx
+
+	vectorAngle := func(ux, uy, vx, vy float64) float64 {
+		return math.Atan2(ux*vy-uy*vx, ux*vx+uy*vy)
+	}
+	theta := vectorAngle(1, 0, (px-cx)/rx, (py-cy)/ry)
+	delta := vectorAngle((px-cx)/rx, (py-cy)/ry, (-px-cx)/rx, (-py-cy)/ry)
+	if !sweep && delta > 0 {
+		delta -= 2 * math.Pi
+	} else if sweep && delta < 0 {
+		delta += 2 * math.Pi
+	}
+
+	centerX := cos*cx - sin*cy + (x1+x2)/2
+	centerY := sin*cx + cos*cy + (y1+y2)/2
+	point := func(t float64) (float64, float64) {
+		s, c := math.Sincos(t)
+		return centerX + cos*rx*c - sin*ry*s, centerY + sin*rx*c + cos*ry*s
+	}
+	tangent := func(t float64) (float64, float64) {
+		s, c := math.Sincos(t)
+		return -cos*rx*s - sin*ry*c, -sin*rx*s + cos*ry*c
+	}
+
+	n := int(math.Ceil(math.Abs(delta) / (math.Pi / 2)))
+	step := delta / float64(n)
+	alpha := 4.0 / 3 * math.Tan(step/4)
+
+	curves := make([][8]float64, 0, n)
+	for i := 0; i < n; i++ {
+		t0, t1 := theta+float64(i)*step, theta+float64(i+1)*step
+		ax, ay := point(t0)
+		bx, by := point(t1)
+		adx, ady := tangent(t0)
+		bdx, bdy := tangent(t1)
+		curves = append(curves, [8]float64{
+			ax, ay,
+			ax + alpha*adx, ay + alpha*ady,
+			bx - alpha*bdx, by - alpha*bdy,
+			bx, by,
+		})
+	}
+	return curves
+}
+
+// Bounds returns the bounding box of the rendered geometry of node in the
+// coordinate system of the root element, including strokes and clip paths.
+//
+// Elements hidden with display:none and elements that aren't rendered
+// directly, such as defs, are skipped. Text isn't measured, convert it
+// to paths with text-to-path first.
+func (doc *Document) Bounds(node *Node) Box {
+	m := Identity
+	for p := node.Parent; p != nil && p.Parent != nil; p = p.Parent {
+		t, _ := ParseTransform(p.Get("transform"))
+		m = t.Mul(m)
+	}
+	return elementBounds(doc.IDs(), node, m, 0)
+}
+
+// maxUseDepth limits how deeply use elements are followed,
+// which protects against reference cycles.
+const maxUseDepth = 16
+
+func elementBounds(ids map[string]*Node, node *Node, m Matrix, depth int) Box {
+	if node.Kind != Element || depth > maxUseDepth {
+		return EmptyBox()
+	}
+	if display, ok := node.Property("display"); ok && display == "none" {
+		return EmptyBox()
+	}
+	t, _ := ParseTransform(node.Get("transform"))
+	m = m.Mul(t)
+
+	number := func(name string) float64 { return parseLength(node.Get(name)) }
+	rect := func(x, y, w, h float64) Path {
+		return Path{
+			{'M', []float64{x, y}},
+			{'H', []float64{x + w}},
+			{'V', []float64{y + h}},
+			{'H', []float64{x}},
+			{'Z', nil},
+		}
+	}
+	ellipse := func(cx, cy, rx, ry float64) Path {
+		return Path{
+			{'M', []float64{cx - rx, cy}},
+			{'A', []float64{rx, ry, 0, 1, 0, cx + rx, cy}},
+			{'A', []float64{rx, ry, 0, 1, 0, cx - rx, cy}},
+			{'Z', nil},
+		}
+	}
+
+	var path Path
+	switch Local(node.Name) {
+	case "g", "a", "switch":
+		box := EmptyBox()
+		for _, child := range node.Elements() {
+			box = box.Union(elementBounds(ids, child, m, depth))
+		}
+		return clipBounds(ids, node, m, box, depth)
+	case "use":
+		target := ids[strings.TrimPrefix(node.Href(), "#")]
+		if target == nil {
+			return EmptyBox()
+		}
+		// x and y are applied after the clip path
+		placed := m.Mul(Matrix{1, 0, 0, 1, number("x"), number("y")})
+		box := EmptyBox()
+		if target.Is("symbol") {
+			for _, child := range target.Elements() {
+				box = box.Union(elementBounds(ids, child, placed, depth+1))
+			}
+		} else {
+			box = elementBounds(ids, target, placed, depth+1)
+		}
+		return clipBounds(ids, node, m, box, depth)
+	case "image":
+		box := rect(number("x"), number("y"), number("width"), number("height")).Bounds(m)
+		return clipBounds(ids, node, m, box, depth)
+	case "path":
+		path, _ = ParsePath(node.Get("d"))
+	case "rect":
+		path = rect(number("x"), number("y"), number("width"), number("height"))
+	case "circle":
+		r := number("r")
+		path = ellipse(number("cx"), number("cy"), r, r)
+	case "ellipse":
+		path = ellipse(number("cx"), number("cy"), number("rx"), number("ry"))
+	case "line":
+		path = Path{
+			{'M', []float64{number("x1"), number("y1")}},
+			{'L', []float64{number("x2"), number("y2")}},
+		}
+	case "polyline", "polygon":
+		points, _ := parseNumbers(node.Get("points"))
+		for k := 0; k+1 < len(points); k += 2 {
+			command := byte('L')
+			if k == 0 {
+				command = 'M'
+			}
+			path = append(path, Segment{command, points[k : k+2]})
+		}
+	default:
+		return EmptyBox()
+	}
+
+	box := path.Bounds(m).Inset(-strokeWidth(node) / 2 * math.Sqrt(math.Abs(m[0]*m[3]-m[1]*m[2])))
+	return clipBounds(ids, node, m, box, depth)
+}
+
+// clipBounds limits box to the clip path of node.
+func clipBounds(ids map[string]*Node, node *Node, m Matrix, box Box, depth int) Box {
+	value, _ := node.Property("clip-path")
+	id, ok := URLRef(value)
+	clip := ids[id]
+	if !ok || clip == nil || box.Empty() || clip.Get("clipPathUnits") == "objectBoundingBox" {
+		return box
+	}
+
+	t, _ := ParseTransform(clip.Get("transform"))
+	m = m.Mul(t)
+	area := EmptyBox()
+	for _, child := range clip.Elements() {
+		area = area.Union(elementBounds(ids, child, m, depth+1))
+	}
+	return box.Intersect(area)
+}
+
+// strokeWidth returns the stroke width of node, or 0 when it has no stroke.
+// Miter joins, which may reach further, are ignored.
+func strokeWidth(node *Node) float64 {
+	stroke, ok := node.Inherited("stroke")
+	if !ok || stroke == "none" {
+		return 0
+	}
+	if value, ok := node.Inherited("stroke-width"); ok {
+		return parseLength(value)
+	}
+	return 1
+}
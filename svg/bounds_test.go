@@ -0,0 +1,82 @@
+package svg
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPathBounds(t *testing.T) {
+	rotate, _ := ParseTransform("rotate(90)")
+	tests := []struct {
+		d    string
+		m    Matrix
+		want Box
+	}{
+		{"M0 0L10 5", Identity, Box{0, 0, 10, 5}},
+		{"M0 0H10V5Z", Identity, Box{0, 0, 10, 5}},
+		// the control points are further out than the curve
+		{"M0 0C0 10 10 10 10 0", Identity, Box{0, 0, 10, 7.5}},
+		{"M0 0C0 10 10 10 10 0", rotate, Box{-7.5, 0, 0, 10}},
+		{"M0 0C0 10 10 10 10 0S20-10 20 0", Identity, Box{0, -7.5, 20, 7.5}},
+		{"M0 0Q5 10 10 0", Identity, Box{0, 0, 10, 5}},
+		{"M0 0Q5 10 10 0T20 0", Identity, Box{0, -5, 20, 5}},
+		// half circles on either side depending on the sweep flag
+		{"M0 0A5 5 0 0 1 10 0", Identity, Box{0, -5, 10, 0}},
+		{"M0 0A5 5 0 0 0 10 0", Identity, Box{0, 0, 10, 5}},
+		{"M-10 0A10 5 0 0 0 10 0A10 5 0 0 0-10 0", Identity, Box{-10, -5, 10, 5}},
+		// the radius is scaled up, when it can't reach the end point
+		{"M0 0A1 1 0 0 1 10 0", Identity, Box{0, -5, 10, 0}},
+		{"M5 5", Identity, EmptyBox()},
+	}
+	for _, test := range tests {
+		path, err := ParsePath(test.d)
+		if err != nil {
+			t.Errorf("%q: %v", test.d, err)
+			continue
+		}
+		if got := path.Bounds(test.m); !sameBox(got, test.want) {
+			t.Errorf("%q: got %v, want %v", test.d, got, test.want)
+		}
+	}
+}
+
+func TestDocumentBounds(t *testing.T) {
+	doc, err := ParseString(`<svg transform="scale(100)">
+		<clipPath id="clip"><rect width="12" height="12"/></clipPath>
+		<g id="moved" transform="translate(10,0)">
+			<rect x="1" y="2" width="3" height="4"/>
+			<circle cx="1" cy="1" r="1" stroke="red" stroke-width="2"/>
+		</g>
+		<g id="clipped" clip-path="url(#clip)"><path d="M0 0H20V20Z"/></g>
+		<g id="hidden" style="display:none"><rect width="5" height="5"/></g>
+		<g id="used"><use href="#moved" x="10"/></g>
+	</svg>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := doc.IDs()
+	tests := []struct {
+		id   string
+		want Box
+	}{
+		{"moved", Box{9, -1, 14, 6}},
+		{"clipped", Box{0, 0, 12, 12}},
+		{"hidden", EmptyBox()},
+		{"used", Box{19, -1, 24, 6}},
+	}
+	for _, test := range tests {
+		if got := doc.Bounds(ids[test.id]); !sameBox(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.id, got, test.want)
+		}
+	}
+}
+
+// sameBox reports whether a and b are equal up to the arc approximation error.
+func sameBox(a, b Box) bool {
+	if a.Empty() || b.Empty() {
+		return a.Empty() == b.Empty()
+	}
+	const eps = 1e-2
+	return math.Abs(a.MinX-b.MinX) < eps && math.Abs(a.MinY-b.MinY) < eps &&
+		math.Abs(a.MaxX-b.MaxX) < eps && math.Abs(a.MaxY-b.MaxY) < eps
+}
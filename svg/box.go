@@ -0,0 +1,66 @@
+package svg
+
+import "math"
+
+// Box is an axis aligned bounding box.
+type Box struct {
+	MinX, MinY float64
+	MaxX, MaxY float64
+}
+
+// EmptyBox returns a box that doesn't contain anything.
+func EmptyBox() Box {
+	return Box{
+		MinX: math.Inf(1), MinY: math.Inf(1),
+		MaxX: math.Inf(-1), MaxY: math.Inf(-1),
+	}
+}
+
+// Empty reports whether the box contains nothing.
+func (box Box) Empty() bool { return box.MinX > box.MaxX || box.MinY > box.MaxY }
+
+// Add returns the box extended to contain x, y.
+func (box Box) Add(x, y float64) Box {
+	box.MinX = math.Min(box.MinX, x)
+	box.MinY = math.Min(box.MinY, y)
+	box.MaxX = math.Max(box.MaxX, x)
+	box.MaxY = math.Max(box.MaxY, y)
+	return box
+}
+
+// Union returns the box containing both boxes.
+func (box Box) Union(other Box) Box {
+	if other.Empty() {
+		return box
+	}
+	return box.Add(other.MinX, other.MinY).Add(other.MaxX, other.MaxY)
+}
+
+// Intersect returns the box contained in both boxes.
+func (box Box) Intersect(other Box) Box {
+	return Box{
+		MinX: math.Max(box.MinX, other.MinX), MinY: math.Max(box.MinY, other.MinY),
+		MaxX: math.Min(box.MaxX, other.MaxX), MaxY: math.Min(box.MaxY, other.MaxY),
+	}
+}
+
+// Inset returns the box shrunk by n on every side, negative n grows the box.
+func (box Box) Inset(n float64) Box {
+	if box.Empty() {
+		return box
+	}
+	return Box{box.MinX + n, box.MinY + n, box.MaxX - n, box.MaxY - n}
+}
+
+// Overlaps reports whether the boxes share any point.
+func (box Box) Overlaps(other Box) bool {
+	return !box.Empty() && !other.Empty() &&
+		box.MinX <= other.MaxX && other.MinX <= box.MaxX &&
+		box.MinY <= other.MaxY && other.MinY <= box.MaxY
+}
+
+// Width returns the width of the box.
+func (box Box) Width() float64 { return math.Max(box.MaxX-box.MinX, 0) }
+
+// Height returns the height of the box.
+func (box Box) Height() float64 { return math.Max(box.MaxY-box.MinY, 0) }
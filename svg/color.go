@@ -0,0 +1,257 @@
+package svg
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ColorProperties are the properties, which contain a color.
+var ColorProperties = []string{"fill", "stroke", "stop-color", "flood-color", "lighting-color", "color"}
+
+// namedColors are the CSS color keywords used in practice.
+var namedColors = map[string]color.NRGBA{
+	"black":   {0x00, 0x00, 0x00, 0xff},
+	"silver":  {0xc0, 0xc0, 0xc0, 0xff},
+	"gray":    {0x80, 0x80, 0x80, 0xff},
+	"grey":    {0x80, 0x80, 0x80, 0xff},
+	"white":   {0xff, 0xff, 0xff, 0xff},
+	"maroon":  {0x80, 0x00, 0x00, 0xff},
+	"red":     {0xff, 0x00, 0x00, 0xff},
+	"purple":  {0x80, 0x00, 0x80, 0xff},
+	"fuchsia": {0xff, 0x00, 0xff, 0xff},
+	"green":   {0x00, 0x80, 0x00, 0xff},
+	"lime":    {0x00, 0xff, 0x00, 0xff},
+	"olive":   {0x80, 0x80, 0x00, 0xff},
+	"yellow":  {0xff, 0xff, 0x00, 0xff},
+	"navy":    {0x00, 0x00, 0x80, 0xff},
+	"blue":    {0x00, 0x00, 0xff, 0xff},
+	"teal":    {0x00, 0x80, 0x80, 0xff},
+	"aqua":    {0x00, 0xff, 0xff, 0xff},
+	"orange":  {0xff, 0xa5, 0x00, 0xff},
+}
+
+// ParseColor parses a color in the form #rgb, #rgba, #rrggbb, #rrggbbaa,
+// rgb(r, g, b), rgba(r, g, b, a) or a basic color keyword.
+func ParseColor(s string) (color.NRGBA, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if c, ok := namedColors[s]; ok {
+		return c, true
+	}
+
+	if strings.HasPrefix(s, "#") {
+		hex := s[1:]
+		if len(hex) == 3 || len(hex) == 4 {
+			var b strings.Builder
+			for _, r := range hex {
+				b.WriteRune(r)
+				b.WriteRune(r)
+			}
+			hex = b.String()
+		}
+		if len(hex) == 6 {
+			hex += "ff"
+		}
+		if len(hex) != 8 {
+			return color.NRGBA{}, false
+		}
+		v, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return color.NRGBA{}, false
+		}
+		return color.NRGBA{uint8(v >> 24), uint8(v >> 16), uint8(v >> 8), uint8(v)}, true
+	}
+
+	for _, fn := range []string{"rgba(", "rgb("} {
+		if !strings.HasPrefix(s, fn) || !strings.HasSuffix(s, ")") {
+			continue
+		}
+		parts := strings.Split(s[len(fn):len(s)-1], ",")
+		if len(parts) != 3 && len(parts) != 4 {
+			return color.NRGBA{}, false
+		}
+		var c [4]uint8
+		c[3] = 0xff
+		for i, part := range parts {
+			part = strings.TrimSpace(part)
+			scale := 1.0
+			if i == 3 {
+				scale = 255
+			}
+			percent := strings.HasSuffix(part, "%")
+			if percent {
+				part, scale = strings.TrimSuffix(part, "%"), 255
+			}
+			v, err := strconv.ParseFloat(part, 64)
+			if err != nil {
+				return color.NRGBA{}, false
+			}
+			if percent {
+				// dividing first keeps 50% at exactly 127.5
+				v /= 100
+			}
+			c[i] = uint8(math.Max(0, math.Min(255, math.Round(v*scale))))
+		}
+		return color.NRGBA{c[0], c[1], c[2], c[3]}, true
+	}
+	return color.NRGBA{}, false
+}
+
+// FormatColor formats c as #rrggbb, the alpha is dropped, because
+// not every renderer supports #rrggbbaa. Use MultiplyOpacity for it.
+func FormatColor(c color.NRGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// opacityProperties are the opacity properties of the color properties.
+var opacityProperties = map[string]string{
+	"fill":        "fill-opacity",
+	"stroke":      "stroke-opacity",
+	"stop-color":  "stop-opacity",
+	"flood-color": "flood-opacity",
+}
+
+// MultiplyOpacity multiplies the opacity of a color property with opacity,
+// keeping it next to the color, either in style or as an attribute.
+// Three decimals are enough to keep 8-bit alpha.
+// Properties without an opacity property are left unchanged.
+func (node *Node) MultiplyOpacity(property string, opacity float64) {
+	name, ok := opacityProperties[property]
+	if !ok || opacity >= 1 {
+		return
+	}
+	current := 1.0
+	if value, ok := node.Property(name); ok {
+		current = parseOpacity(value)
+	}
+	style := node.Style()
+	_, colorInStyle := style.Get(property)
+	_, opacityInStyle := style.Get(name)
+	if !colorInStyle && !opacityInStyle {
+		node.Set(name, formatNumber(current*opacity, 3))
+	} else {
+		node.SetProperty(name, formatNumber(current*opacity, 3))
+	}
+}
+
+// ColorDistance returns the euclidean distance of the colors in RGB.
+func ColorDistance(a, b color.NRGBA) float64 {
+	dr := float64(a.R) - float64(b.R)
+	dg := float64(a.G) - float64(b.G)
+	db := float64(a.B) - float64(b.B)
+	return math.Sqrt(dr*dr + dg*dg + db*db)
+}
+
+// ShiftHue rotates the hue of c by degrees, keeping saturation and lightness.
+func ShiftHue(c color.NRGBA, degrees float64) color.NRGBA {
+	r, g, b := float64(c.R)/255, float64(c.G)/255, float64(c.B)/255
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+	l := (max + min) / 2
+	if max == min {
+		// gray has no hue
+		return c
+	}
+
+	d := max - min
+	s := d / (1 - math.Abs(2*l-1))
+	var h float64
+	switch max {
+	case r:
+		h = math.Mod((g-b)/d, 6)
+	case g:
+		h = (b-r)/d + 2
+	default:
+		h = (r-g)/d + 4
+	}
+	h = math.Mod(h*60+degrees, 360)
+	if h < 0 {
+		h += 360
+	}
+
+	chroma := (1 - math.Abs(2*l-1)) * s
+	x := chroma * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	var rgb [3]float64
+	switch {
+	case h < 60:
+		rgb = [3]float64{chroma, x, 0}
+	case h < 120:
+		rgb = [3]float64{x, chroma, 0}
+	case h < 180:
+		rgb = [3]float64{0, chroma, x}
+	case h < 240:
+		rgb = [3]float64{0, x, chroma}
+	case h < 300:
+		rgb = [3]float64{x, 0, chroma}
+	default:
+		rgb = [3]float64{chroma, 0, x}
+	}
+	m := l - chroma/2
+	to8 := func(v float64) uint8 { return uint8(math.Max(0, math.Min(255, math.Round((v+m)*255)))) }
+	return color.NRGBA{to8(rgb[0]), to8(rgb[1]), to8(rgb[2]), c.A}
+}
+
+// MapColors replaces the colors of fills, strokes, gradient stops and
+// other color properties, both in style and presentation attributes.
+// Values that aren't plain colors, such as "none" or "url(#id)", are kept.
+// Translucent results are written as an opaque color and the alpha is
+// multiplied into fill-opacity, stroke-opacity, stop-opacity or flood-opacity.
+//
+// It returns the number of replaced values.
+func (doc *Document) MapColors(fn func(color.NRGBA) (color.NRGBA, bool)) int {
+	replace := func(value string) (string, uint8, bool) {
+		c, ok := ParseColor(value)
+		if !ok {
+			return value, 0, false
+		}
+		r, ok := fn(c)
+		if !ok || r == c {
+			return value, 0, false
+		}
+		return FormatColor(r), r.A, true
+	}
+
+	count := 0
+	doc.Walk(func(node *Node) bool {
+		if node.Kind != Element {
+			return true
+		}
+		// style overrides the attribute, so its alpha is the one used
+		alpha := map[string]uint8{}
+		for _, name := range ColorProperties {
+			if value, a, ok := replace(node.Get(name)); ok {
+				node.Set(name, value)
+				alpha[name] = a
+				count++
+			}
+		}
+		if node.Has("style") {
+			style := node.Style()
+			modified := false
+			for i, decl := range style {
+				if !contains(ColorProperties, decl.Name) {
+					continue
+				}
+				delete(alpha, decl.Name)
+				if value, a, ok := replace(decl.Value); ok {
+					style[i].Value = value
+					alpha[decl.Name] = a
+					modified = true
+					count++
+				}
+			}
+			if modified {
+				node.SetStyle(style)
+			}
+		}
+		for _, name := range ColorProperties {
+			if a, ok := alpha[name]; ok {
+				node.MultiplyOpacity(name, float64(a)/0xff)
+			}
+		}
+		return true
+	})
+	return count
+}
@@ -0,0 +1,105 @@
+package svg
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		in   string
+		want color.NRGBA
+	}{
+		{"#f80", color.NRGBA{0xff, 0x88, 0x00, 0xff}},
+		{"#F808", color.NRGBA{0xff, 0x88, 0x00, 0x88}},
+		{"#123456", color.NRGBA{0x12, 0x34, 0x56, 0xff}},
+		{"#12345678", color.NRGBA{0x12, 0x34, 0x56, 0x78}},
+		{" Navy ", color.NRGBA{0x00, 0x00, 0x80, 0xff}},
+		{"rgb(255, 128, 0)", color.NRGBA{0xff, 0x80, 0x00, 0xff}},
+		{"rgb(100%,50%,300)", color.NRGBA{0xff, 0x80, 0xff, 0xff}},
+		{"rgba(0,0,0,0.5)", color.NRGBA{0x00, 0x00, 0x00, 0x80}},
+	}
+	for _, test := range tests {
+		got, ok := ParseColor(test.in)
+		if !ok || got != test.want {
+			t.Errorf("%q: got %v, %v, want %v", test.in, got, ok, test.want)
+		}
+	}
+
+	for _, bad := range []string{"", "none", "url(#a)", "#12", "#ggg", "rgb(1,2)", "rgb(1,2,x)", "currentColor"} {
+		if got, ok := ParseColor(bad); ok {
+			t.Errorf("%q: expected no color, got %v", bad, got)
+		}
+	}
+}
+
+func TestFormatColor(t *testing.T) {
+	if got := FormatColor(color.NRGBA{0x12, 0x34, 0x56, 0xff}); got != "#123456" {
+		t.Errorf("got %q", got)
+	}
+	// the alpha goes into the opacity properties
+	if got := FormatColor(color.NRGBA{0x12, 0x34, 0x56, 0x78}); got != "#123456" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestShiftHue(t *testing.T) {
+	tests := []struct {
+		in      color.NRGBA
+		degrees float64
+		want    color.NRGBA
+	}{
+		{color.NRGBA{0xff, 0x00, 0x00, 0xff}, 120, color.NRGBA{0x00, 0xff, 0x00, 0xff}},
+		{color.NRGBA{0xff, 0x00, 0x00, 0x80}, -120, color.NRGBA{0x00, 0x00, 0xff, 0x80}},
+		{color.NRGBA{0x00, 0x00, 0xff, 0xff}, 480, color.NRGBA{0xff, 0x00, 0x00, 0xff}},
+		{color.NRGBA{0xff, 0x80, 0x80, 0xff}, 180, color.NRGBA{0x80, 0xff, 0xff, 0xff}},
+		// gray has no hue
+		{color.NRGBA{0x80, 0x80, 0x80, 0xff}, 90, color.NRGBA{0x80, 0x80, 0x80, 0xff}},
+	}
+	for _, test := range tests {
+		if got := ShiftHue(test.in, test.degrees); got != test.want {
+			t.Errorf("%v by %v: got %v, want %v", test.in, test.degrees, got, test.want)
+		}
+	}
+}
+
+func TestMapColors(t *testing.T) {
+	doc, err := ParseString(`<svg><path fill="red" stroke="none" style="stroke:#ff0000;fill:url(#a)"/><stop stop-color="#00f"/></svg>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	red := color.NRGBA{0xff, 0x00, 0x00, 0xff}
+	count := doc.MapColors(func(c color.NRGBA) (color.NRGBA, bool) {
+		if c != red {
+			return c, false
+		}
+		return color.NRGBA{0x00, 0x80, 0x00, 0xff}, true
+	})
+	if count != 2 {
+		t.Errorf("replaced %d colors, expected 2", count)
+	}
+	want := `<svg><path fill="#008000" stroke="none" style="stroke:#008000;fill:url(#a)"/><stop stop-color="#00f"/></svg>`
+	if got := string(doc.Bytes()); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestMapColorsTranslucent(t *testing.T) {
+	doc, err := ParseString(`<svg><path fill="red" fill-opacity="0.5" style="stroke:red"/><stop stop-color="red"/><path style="fill:red" fill="blue"/></svg>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := doc.MapColors(func(c color.NRGBA) (color.NRGBA, bool) {
+		if c.R != 0xff {
+			return c, false
+		}
+		return color.NRGBA{0x00, 0x80, 0x00, 0x80}, true
+	})
+	if count != 4 {
+		t.Errorf("replaced %d colors, expected 4", count)
+	}
+	want := `<svg><path fill="#008000" fill-opacity=".251" style="stroke:#008000;stroke-opacity:.502"/><stop stop-color="#008000" stop-opacity=".502"/><path style="fill:#008000;fill-opacity:.502" fill="blue"/></svg>`
+	if got := string(doc.Bytes()); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
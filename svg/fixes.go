@@ -0,0 +1,368 @@
+package svg
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RestorePrologPass restores XML declarations, which were turned
+// into comments by an HTML parser. A declaration is only valid at the
+// start of the document, so the comment is removed, when the document
+// already has a declaration or the comment isn't the first node.
+var RestorePrologPass = &Pass{
+	Name:        "restore-prolog",
+	Description: "restore XML declaration turned into a comment",
+	Run: func(doc *Document, opts *Options, report *Report) {
+		declared := false
+		for _, node := range doc.Nodes {
+			if node.Kind == ProcInst && strings.HasPrefix(node.Raw, "xml ") {
+				declared = true
+			}
+		}
+
+		nodes := doc.Nodes[:0]
+		for i, node := range doc.Nodes {
+			raw := node.Raw
+			if node.Kind != Comment || !strings.HasPrefix(raw, "?xml ") || !strings.HasSuffix(raw, "?") {
+				nodes = append(nodes, node)
+				continue
+			}
+
+			if i == 0 && !declared {
+				node.Kind = ProcInst
+				node.Raw = raw[1 : len(raw)-1]
+				declared = true
+				nodes = append(nodes, node)
+				report.Changef("restored <?%s?>", node.Raw)
+				continue
+			}
+
+			// drop the whitespace before the comment together with it
+			if n := len(nodes); n > 0 && nodes[n-1].IsSpace() {
+				nodes = nodes[:n-1]
+			}
+			report.Changef("removed <!--%s-->", raw)
+		}
+		doc.Nodes = nodes
+	},
+}
+
+// camelCase lists SVG names with uppercase letters.
+var camelCase = func() map[string]string {
+	names := []string{
+		// elements
+		"altGlyph", "altGlyphDef", "altGlyphItem", "animateColor", "animateMotion",
+		"animateTransform", "clipPath", "feBlend", "feColorMatrix",
+		"feComponentTransfer", "feComposite", "feConvolveMatrix",
+		"feDiffuseLighting", "feDisplacementMap", "feDistantLight",
+		"feDropShadow", "feFlood", "feFuncA", "feFuncB", "feFuncG", "feFuncR",
+		"feGaussianBlur", "feImage", "feMerge", "feMergeNode", "feMorphology",
+		"feOffset", "fePointLight", "feSpecularLighting", "feSpotLight",
+		"feTile", "feTurbulence", "foreignObject", "glyphRef",
+		"linearGradient", "radialGradient", "textPath",
+		// attributes
+		"attributeName", "attributeType", "baseFrequency", "baseProfile",
+		"calcMode", "clipPathUnits", "contentScriptType", "contentStyleType",
+		"diffuseConstant", "edgeMode", "filterRes", "filterUnits",
+		"glyphRef", "gradientTransform", "gradientUnits", "kernelMatrix",
+		"kernelUnitLength", "keyPoints", "keySplines", "keyTimes",
+		"lengthAdjust", "limitingConeAngle", "markerHeight", "markerUnits",
+		"markerWidth", "maskContentUnits", "maskUnits", "numOctaves",
+		"pathLength", "patternContentUnits", "patternTransform",
+		"patternUnits", "pointsAtX", "pointsAtY", "pointsAtZ",
+		"preserveAlpha", "preserveAspectRatio", "primitiveUnits", "refX",
+		"refY", "repeatCount", "repeatDur", "requiredExtensions",
+		"requiredFeatures", "specularConstant", "specularExponent",
+		"spreadMethod", "startOffset", "stdDeviation", "stitchTiles",
+		"surfaceScale", "systemLanguage", "tableValues", "targetX", "targetY",
+		"textLength", "viewBox", "viewTarget", "xChannelSelector",
+		"yChannelSelector", "zoomAndPan",
+	}
+
+	m := map[string]string{}
+	for _, name := range names {
+		m[strings.ToLower(name)] = name
+	}
+	return m
+}()
+
+// RestoreCasePass restores the case of SVG names,
+// which were lowercased by an HTML parser.
+var RestoreCasePass = &Pass{
+	Name:        "restore-case",
+	Description: "restore case of lowercased SVG element and attribute names",
+	Run: func(doc *Document, opts *Options, report *Report) {
+		restore := func(name string) string {
+			prefix := Prefix(name)
+			if prefix != "" && prefix != "svg" {
+				return name
+			}
+			if fixed, ok := camelCase[Local(name)]; ok {
+				if prefix != "" {
+					return prefix + ":" + fixed
+				}
+				return fixed
+			}
+			return name
+		}
+
+		renamed := map[string]bool{}
+		doc.Walk(func(node *Node) bool {
+			if node.Kind != Element {
+				return true
+			}
+			if fixed := restore(node.Name); fixed != node.Name {
+				renamed[node.Name+" -> "+fixed] = true
+				node.Name = fixed
+			}
+			for _, attr := range node.Attrs {
+				if fixed := restore(attr.Name); fixed != attr.Name {
+					renamed[attr.Name+" -> "+fixed] = true
+					attr.Name = fixed
+				}
+			}
+			return true
+		})
+
+		for _, name := range sortedKeys(renamed) {
+			report.Changef("renamed %s", name)
+		}
+	},
+}
+
+// RemoveVisibilityPass removes "visibility:visible" from styles,
+// which is the default and confuses Affinity Designer.
+var RemoveVisibilityPass = &Pass{
+	Name:        "remove-visibility",
+	Description: "remove visibility:visible from styles",
+	Default:     true,
+	Run: func(doc *Document, opts *Options, report *Report) {
+		doc.Walk(func(node *Node) bool {
+			if node.Kind != Element || !node.Has("style") {
+				return true
+			}
+
+			style := node.Style()
+			if value, ok := style.Get("visibility"); ok && value == "visible" {
+				style.Remove("visibility")
+				node.SetStyle(style)
+				report.Changef("%s: removed visibility:visible", describe(node))
+			}
+			return true
+		})
+	},
+}
+
+// IsGradient reports whether node is a linear or radial gradient.
+func (node *Node) IsGradient() bool {
+	return node.Is("linearGradient") || node.Is("radialGradient")
+}
+
+// GradientStops returns the stops of gradient, which may be inherited
+// through href from other gradients.
+func GradientStops(ids map[string]*Node, gradient *Node) []*Node {
+	for seen := map[*Node]bool{}; gradient != nil && gradient.IsGradient() && !seen[gradient]; {
+		seen[gradient] = true
+
+		var stops []*Node
+		for _, child := range gradient.Children {
+			if child.Is("stop") {
+				stops = append(stops, child)
+			}
+		}
+		if len(stops) > 0 {
+			return stops
+		}
+
+		href := gradient.Href()
+		if !strings.HasPrefix(href, "#") {
+			break
+		}
+		gradient = ids[href[1:]]
+	}
+	return nil
+}
+
+// stopColor returns the color and opacity of a gradient stop.
+func stopColor(stop *Node) (string, float64) {
+	color, ok := stop.Property("stop-color")
+	if !ok || color == "" {
+		color = "#000000"
+	}
+	opacity := 1.0
+	if value, ok := stop.Property("stop-opacity"); ok {
+		opacity = parseOpacity(value)
+	}
+	return color, opacity
+}
+
+// parseOpacity parses an opacity as a number or a percentage,
+// invalid values are treated as fully opaque.
+func parseOpacity(value string) float64 {
+	value = strings.TrimSpace(value)
+	scale := 1.0
+	if strings.HasSuffix(value, "%") {
+		value, scale = strings.TrimSuffix(value, "%"), 0.01
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 1
+	}
+	return math.Max(0, math.Min(1, v*scale))
+}
+
+// solidGradient returns the color and opacity of a gradient,
+// where all stops are the same or which has no stops.
+func solidGradient(ids map[string]*Node, id string) (string, float64, bool) {
+	gradient := ids[id]
+	if gradient == nil || !gradient.IsGradient() {
+		return "", 0, false
+	}
+
+	stops := GradientStops(ids, gradient)
+	if len(stops) == 0 {
+		// gradients without stops are not painted
+		return "none", 1, true
+	}
+
+	color, opacity := stopColor(stops[0])
+	for _, stop := range stops[1:] {
+		c, o := stopColor(stop)
+		if !strings.EqualFold(c, color) || o != opacity {
+			return "", 0, false
+		}
+	}
+	return color, opacity, true
+}
+
+// InlineGradientsPass replaces references to gradients
+// with a single color by the color.
+var InlineGradientsPass = &Pass{
+	Name:        "inline-gradients",
+	Description: "replace single color gradients in fill and stroke with a color",
+	Default:     true,
+	Run: func(doc *Document, opts *Options, report *Report) {
+		ids := doc.IDs()
+		doc.Walk(func(node *Node) bool {
+			if node.Kind != Element {
+				return true
+			}
+
+			for _, property := range []string{"fill", "stroke"} {
+				value, _ := node.Property(property)
+				id, ok := URLRef(value)
+				if !ok {
+					continue
+				}
+				color, opacity, ok := solidGradient(ids, id)
+				if !ok {
+					continue
+				}
+
+				node.SetProperty(property, color)
+				opts.inlined[id] = true
+				if color != "none" {
+					node.MultiplyOpacity(property, opacity)
+				}
+				report.Changef("%s: %s url(#%s) -> %s", describe(node), property, id, color)
+			}
+			return true
+		})
+	},
+}
+
+// rxURL matches url(#id) references.
+var rxURL = regexp.MustCompile(`url\(\s*["']?#([^)"'\s]+)`)
+
+// References returns the ids referenced by url(#id) or href="#id".
+func (doc *Document) References() map[string]bool {
+	refs := map[string]bool{}
+	doc.Walk(func(node *Node) bool {
+		for _, id := range node.References() {
+			refs[id] = true
+		}
+		return true
+	})
+	return refs
+}
+
+// References returns the ids referenced by the attributes of an element
+// or by the content of a text node inside a <style> element.
+func (node *Node) References() []string {
+	var refs []string
+	switch node.Kind {
+	case Element:
+		for _, attr := range node.Attrs {
+			if Local(attr.Name) == "href" && strings.HasPrefix(attr.Value, "#") {
+				refs = append(refs, attr.Value[1:])
+			}
+			for _, match := range rxURL.FindAllStringSubmatch(attr.Value, -1) {
+				refs = append(refs, match[1])
+			}
+		}
+	case Text, CData:
+		for _, match := range rxURL.FindAllStringSubmatch(node.Text(), -1) {
+			refs = append(refs, match[1])
+		}
+	}
+	return refs
+}
+
+// RemoveDeadGradientsPass removes gradients replaced by inline-gradients,
+// which are not referenced anymore, and the gradients they inherit from.
+var RemoveDeadGradientsPass = &Pass{
+	Name:        "remove-dead-gradients",
+	Description: "remove gradients, which were inlined and are not referenced anymore",
+	Default:     true,
+	Run: func(doc *Document, opts *Options, report *Report) {
+		dead := map[string]bool{}
+		for id := range opts.inlined {
+			dead[id] = true
+		}
+		removeGradients(doc, report, func(node *Node) bool { return dead[node.ID()] }, func(node *Node) {
+			if href := node.Href(); strings.HasPrefix(href, "#") {
+				dead[href[1:]] = true
+			}
+		})
+	},
+}
+
+// RemoveUnusedGradientsPass removes every unreferenced gradient,
+// including Inkscape swatches.
+var RemoveUnusedGradientsPass = &Pass{
+	Name:        "remove-unused-gradients",
+	Description: "remove all unreferenced gradients, including swatches",
+	Run: func(doc *Document, opts *Options, report *Report) {
+		removeGradients(doc, report, func(node *Node) bool { return true }, func(node *Node) {})
+	},
+}
+
+// removeGradients removes unreferenced gradients accepted by candidate
+// and calls removed for each of them.
+func removeGradients(doc *Document, report *Report, candidate func(*Node) bool, removed func(*Node)) {
+	// removing a gradient may make the gradients it references dead
+	for changed := true; changed; {
+		changed = false
+		refs := doc.References()
+		doc.Walk(func(node *Node) bool {
+			if node.IsGradient() && !refs[node.ID()] && node.Parent != nil && candidate(node) {
+				node.Parent.RemoveChild(node)
+				report.Changef("removed %s", describe(node))
+				removed(node)
+				changed = true
+				return false
+			}
+			return true
+		})
+	}
+}
+
+// describe returns a short description of node for reports.
+func describe(node *Node) string {
+	if id := node.ID(); id != "" {
+		return node.Name + "#" + id
+	}
+	return node.Name
+}
@@ -0,0 +1,86 @@
+package svg
+
+import "testing"
+
+func TestSolidGradient(t *testing.T) {
+	doc, err := ParseString(`<svg xmlns:xlink="http://www.w3.org/1999/xlink">
+		<linearGradient id="base"><stop offset="0" stop-color="#f00"/><stop offset="1" style="stop-color:#F00"/></linearGradient>
+		<linearGradient id="linked" xlink:href="#base"/>
+		<radialGradient id="chained" href="#linked" cx="5"/>
+		<radialGradient id="faded"><stop style="stop-color:#00f;stop-opacity:50%"/><stop stop-color="#00f" stop-opacity=".5"/></radialGradient>
+		<linearGradient id="ramp"><stop stop-color="#000"/><stop stop-color="#fff"/></linearGradient>
+		<linearGradient id="empty"/>
+		<linearGradient id="loop1" href="#loop2"/>
+		<linearGradient id="loop2" href="#loop1"/>
+		<linearGradient id="default"><stop/></linearGradient>
+		<pattern id="pattern"/>
+	</svg>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := doc.IDs()
+
+	tests := []struct {
+		id      string
+		color   string
+		opacity float64
+		ok      bool
+	}{
+		{"base", "#f00", 1, true},
+		{"linked", "#f00", 1, true},
+		{"chained", "#f00", 1, true},
+		{"faded", "#00f", 0.5, true},
+		{"ramp", "", 0, false},
+		{"empty", "none", 1, true},
+		{"loop1", "none", 1, true},
+		{"default", "#000000", 1, true},
+		{"pattern", "", 0, false},
+		{"missing", "", 0, false},
+	}
+	for _, test := range tests {
+		color, opacity, ok := solidGradient(ids, test.id)
+		if color != test.color || opacity != test.opacity || ok != test.ok {
+			t.Errorf("%s: got %q, %v, %v, want %q, %v, %v", test.id, color, opacity, ok, test.color, test.opacity, test.ok)
+		}
+	}
+
+	if stops := GradientStops(ids, ids["chained"]); len(stops) != 2 || stops[0].Parent != ids["base"] {
+		t.Errorf("chained gradient has %d stops", len(stops))
+	}
+}
+
+func TestParseOpacity(t *testing.T) {
+	tests := map[string]float64{
+		"0.5": 0.5, " 1 ": 1, "50%": 0.5, "2": 1, "-1": 0, "x": 1, "": 1,
+	}
+	for in, want := range tests {
+		if got := parseOpacity(in); got != want {
+			t.Errorf("%q: got %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestStyle(t *testing.T) {
+	style := ParseStyle(" fill : red ;; stroke:url(#a);bad ")
+	if got := style.String(); got != "fill:red;stroke:url(#a)" {
+		t.Errorf("got %q", got)
+	}
+
+	node := NewElement("path")
+	node.Set("stroke", "blue")
+	node.SetStyle(style)
+	node.SetProperty("fill", "green")
+	node.SetProperty("opacity", "0.5")
+	if got := node.Get("style"); got != "fill:green;stroke:url(#a);opacity:0.5" {
+		t.Errorf("got style %q", got)
+	}
+	if value, _ := node.Property("stroke"); value != "url(#a)" {
+		t.Errorf("style should win over attributes, got stroke %q", value)
+	}
+
+	for in, want := range map[string]string{"url(#a)": "a", ` url( "#b" ) `: "b", "url(x.svg#c)": "", "#d": ""} {
+		if got, ok := URLRef(in); got != want || ok != (want != "") {
+			t.Errorf("%q: got %q, %v", in, got, ok)
+		}
+	}
+}
@@ -0,0 +1,370 @@
+package svg
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Severity is the importance of a lint problem.
+type Severity int
+
+const (
+	Warning Severity = iota
+	Error
+)
+
+func (severity Severity) String() string {
+	if severity == Error {
+		return "error"
+	}
+	return "warning"
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (severity Severity) MarshalText() ([]byte, error) {
+	return []byte(severity.String()), nil
+}
+
+// Problem is a single lint finding.
+type Problem struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Line     int      `json:"line,omitempty"`
+	Element  string   `json:"element,omitempty"`
+	Message  string   `json:"message"`
+}
+
+// Rule checks a document for a single kind of problem.
+type Rule struct {
+	Name        string
+	Description string
+	Severity    Severity
+	Check       func(doc *Document, report func(node *Node, format string, args ...interface{}))
+}
+
+// Rules lists all lint rules.
+var Rules = []*Rule{
+	MissingViewBoxRule,
+	PhysicalSizeRule,
+	EmbeddedRasterRule,
+	ExternalReferenceRule,
+	UnresolvedReferenceRule,
+	DuplicateIDRule,
+	FontRule,
+	AffinityRule,
+}
+
+// LookupRule finds a rule by name.
+func LookupRule(name string) *Rule {
+	for _, rule := range Rules {
+		if rule.Name == name {
+			return rule
+		}
+	}
+	return nil
+}
+
+// SelectRules parses a comma separated list of rule names,
+// "all" selects every rule and "-name" excludes a rule.
+func SelectRules(spec string) ([]*Rule, error) {
+	selected := map[*Rule]bool{}
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		enable := !strings.HasPrefix(name, "-")
+		name = strings.TrimPrefix(name, "-")
+
+		switch name {
+		case "":
+		case "all":
+			for _, rule := range Rules {
+				selected[rule] = enable
+			}
+		default:
+			rule := LookupRule(name)
+			if rule == nil {
+				return nil, fmt.Errorf("unknown rule %q", name)
+			}
+			selected[rule] = enable
+		}
+	}
+
+	var rules []*Rule
+	for _, rule := range Rules {
+		if selected[rule] {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+// Lint checks doc with the rules and returns the problems sorted by line.
+func Lint(doc *Document, rules []*Rule) []Problem {
+	var problems []Problem
+	for _, rule := range rules {
+		rule.Check(doc, func(node *Node, format string, args ...interface{}) {
+			problem := Problem{
+				Rule:     rule.Name,
+				Severity: rule.Severity,
+				Message:  fmt.Sprintf(format, args...),
+			}
+			if node != nil {
+				problem.Line = node.Line
+				problem.Element = describe(node)
+			}
+			problems = append(problems, problem)
+		})
+	}
+	sort.SliceStable(problems, func(i, k int) bool {
+		return problems[i].Line < problems[k].Line
+	})
+	return problems
+}
+
+// MissingViewBoxRule reports documents without a viewBox,
+// which can't be scaled reliably.
+var MissingViewBoxRule = &Rule{
+	Name:        "missing-viewbox",
+	Description: "the root element must have a viewBox",
+	Severity:    Error,
+	Check: func(doc *Document, report func(node *Node, format string, args ...interface{})) {
+		root := doc.Root()
+		if root == nil {
+			report(nil, "document has no root element")
+			return
+		}
+		if !root.Has("viewBox") {
+			report(root, "missing viewBox")
+		}
+	},
+}
+
+// PhysicalSizeRule reports documents sized in physical units,
+// which are scaled differently by every tool.
+var PhysicalSizeRule = &Rule{
+	Name:        "physical-size",
+	Description: "width and height should not use mm, cm, in, pt or pc",
+	Severity:    Warning,
+	Check: func(doc *Document, report func(node *Node, format string, args ...interface{})) {
+		root := doc.Root()
+		if root == nil {
+			return
+		}
+		for _, name := range []string{"width", "height"} {
+			value := strings.TrimSpace(root.Get(name))
+			for _, unit := range []string{"mm", "cm", "in", "pt", "pc"} {
+				if strings.HasSuffix(value, unit) {
+					report(root, "%s is %q, use px or unitless sizes", name, value)
+				}
+			}
+		}
+	},
+}
+
+// EmbeddedRasterRule reports images embedded as data URIs.
+var EmbeddedRasterRule = &Rule{
+	Name:        "embedded-raster",
+	Description: "images should be vector graphics instead of embedded rasters",
+	Severity:    Warning,
+	Check: func(doc *Document, report func(node *Node, format string, args ...interface{})) {
+		for _, image := range doc.ElementsByName("image") {
+			href := image.Href()
+			if !strings.HasPrefix(href, "data:") {
+				continue
+			}
+			kind := strings.TrimPrefix(href, "data:")
+			if i := strings.IndexAny(kind, ";,"); i >= 0 {
+				kind = kind[:i]
+			}
+			report(image, "embedded %s image, %d bytes", kind, len(href))
+		}
+	},
+}
+
+var (
+	rxExternalURL = regexp.MustCompile(`url\(\s*["']?([^#)"'\s][^)"'\s]*)`)
+	rxImport      = regexp.MustCompile(`@import\s+(?:url\()?\s*["']?([^"');\s]+)`)
+)
+
+// ExternalReferenceRule reports references to other files,
+// which break when the file is moved or shared.
+var ExternalReferenceRule = &Rule{
+	Name:        "external-reference",
+	Description: "all references must point inside the document",
+	Severity:    Error,
+	Check: func(doc *Document, report func(node *Node, format string, args ...interface{})) {
+		doc.Walk(func(node *Node) bool {
+			switch node.Kind {
+			case Element:
+				for _, attr := range node.Attrs {
+					if Local(attr.Name) == "href" && attr.Value != "" &&
+						!strings.HasPrefix(attr.Value, "#") &&
+						!strings.HasPrefix(attr.Value, "data:") &&
+						!node.Is("a") {
+						report(node, "%s references %q", attr.Name, attr.Value)
+					}
+					for _, match := range rxExternalURL.FindAllStringSubmatch(attr.Value, -1) {
+						if !strings.HasPrefix(match[1], "data:") {
+							report(node, "%s references %q", attr.Name, match[1])
+						}
+					}
+				}
+			case Text, CData:
+				text := node.Text()
+				for _, match := range rxExternalURL.FindAllStringSubmatch(text, -1) {
+					if !strings.HasPrefix(match[1], "data:") {
+						report(node.Parent, "style references %q", match[1])
+					}
+				}
+				for _, match := range rxImport.FindAllStringSubmatch(text, -1) {
+					report(node.Parent, "style imports %q", match[1])
+				}
+			}
+			return true
+		})
+	},
+}
+
+// UnresolvedReferenceRule reports references to missing ids.
+var UnresolvedReferenceRule = &Rule{
+	Name:        "unresolved-reference",
+	Description: "url(#id) and href=\"#id\" must reference existing ids",
+	Severity:    Error,
+	Check: func(doc *Document, report func(node *Node, format string, args ...interface{})) {
+		ids := doc.IDs()
+		doc.Walk(func(node *Node) bool {
+			for _, id := range node.References() {
+				if ids[id] == nil {
+					element := node
+					if node.Kind != Element {
+						element = node.Parent
+					}
+					report(element, "reference to missing #%s", id)
+				}
+			}
+			return true
+		})
+	},
+}
+
+// DuplicateIDRule reports ids used by several elements.
+var DuplicateIDRule = &Rule{
+	Name:        "duplicate-id",
+	Description: "ids must be unique",
+	Severity:    Error,
+	Check: func(doc *Document, report func(node *Node, format string, args ...interface{})) {
+		first := map[string]*Node{}
+		doc.Walk(func(node *Node) bool {
+			id := node.ID()
+			if node.Kind != Element || id == "" {
+				return true
+			}
+			if other, ok := first[id]; ok {
+				report(node, "duplicate id %q, first used on line %d", id, other.Line)
+			} else {
+				first[id] = node
+			}
+			return true
+		})
+	},
+}
+
+// genericFonts are the font families, which are always available.
+var genericFonts = map[string]bool{
+	"serif": true, "sans-serif": true, "monospace": true,
+	"cursive": true, "fantasy": true, "system-ui": true,
+	"inherit": true,
+}
+
+// FontRule reports text using fonts, which need to be installed.
+var FontRule = &Rule{
+	Name:        "font",
+	Description: "text should be converted to paths instead of requiring fonts",
+	Severity:    Warning,
+	Check: func(doc *Document, report func(node *Node, format string, args ...interface{})) {
+		reported := map[string]bool{}
+		doc.Walk(func(node *Node) bool {
+			if !node.Is("text") && !node.Is("flowRoot") {
+				return true
+			}
+			if strings.TrimSpace(node.Text()) == "" {
+				return false
+			}
+
+			families := map[string]bool{}
+			// the font may be inherited or set on a tspan
+			for n := node; n != nil; n = n.Parent {
+				if family, ok := n.Property("font-family"); ok {
+					families[family] = true
+					break
+				}
+			}
+			node.Walk(func(child *Node) bool {
+				if family, ok := child.Property("font-family"); ok && child != node {
+					families[family] = true
+				}
+				return true
+			})
+
+			for _, family := range sortedKeys(families) {
+				first := strings.Trim(strings.TrimSpace(strings.Split(family, ",")[0]), `"'`)
+				if first == "" || genericFonts[strings.ToLower(first)] || reported[first] {
+					continue
+				}
+				reported[first] = true
+				report(node, "text uses font %q", first)
+			}
+			return false
+		})
+	},
+}
+
+// AffinityRule reports constructs, which Affinity Designer doesn't import
+// correctly. Most of them can be fixed with fix-svg-style.
+var AffinityRule = &Rule{
+	Name:        "affinity",
+	Description: "constructs Affinity Designer doesn't handle",
+	Severity:    Warning,
+	Check: func(doc *Document, report func(node *Node, format string, args ...interface{})) {
+		for _, node := range doc.Nodes {
+			if node.Kind == Comment && strings.HasPrefix(node.Raw, "?xml ") {
+				report(nil, "XML declaration inside a comment (restore-prolog)")
+			}
+		}
+
+		ids := doc.IDs()
+		doc.Walk(func(node *Node) bool {
+			if node.Kind != Element {
+				return true
+			}
+
+			if fixed, ok := camelCase[Local(node.Name)]; ok && fixed != Local(node.Name) {
+				report(node, "lowercased element %s (restore-case)", fixed)
+			}
+			for _, attr := range node.Attrs {
+				if fixed, ok := camelCase[Local(attr.Name)]; ok && fixed != Local(attr.Name) && Prefix(attr.Name) == "" {
+					report(node, "lowercased attribute %s (restore-case)", fixed)
+				}
+			}
+
+			if value, ok := node.Style().Get("visibility"); ok && value == "visible" {
+				report(node, "visibility:visible (remove-visibility)")
+			}
+			for _, property := range []string{"fill", "stroke"} {
+				value, _ := node.Property(property)
+				if id, ok := URLRef(value); ok {
+					if _, _, solid := solidGradient(ids, id); solid {
+						report(node, "%s uses single color gradient #%s (inline-gradients)", property, id)
+					}
+				}
+			}
+
+			if node.Is("flowRoot") {
+				report(node, "SVG 1.2 flowed text, convert it to regular text")
+				return false
+			}
+			return true
+		})
+	},
+}
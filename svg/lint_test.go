@@ -0,0 +1,66 @@
+package svg
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		rule string
+		in   string
+		want []string
+	}{
+		{"missing-viewbox", `<svg width="10"/>`,
+			[]string{"1 svg: missing viewBox"}},
+		{"missing-viewbox", `<svg viewBox="0 0 1 1"/>`, nil},
+		{"physical-size", `<svg width="10mm" height="1in"/>`,
+			[]string{`1 svg: width is "10mm", use px or unitless sizes`, `1 svg: height is "1in", use px or unitless sizes`}},
+		{"physical-size", `<svg width="10px" height="100%"/>`, nil},
+		{"embedded-raster", "<svg>\n" + `<image href="data:image/png;base64,AAAA"/><image href="#a"/></svg>`,
+			[]string{"2 image: embedded image/png image, 26 bytes"}},
+		{"external-reference", "<svg>\n" + `<use href="other.svg#a"/><path fill="url(file.svg#b)"/><a href="https://golang.org"/><style>@import "x.css";</style></svg>`,
+			[]string{`2 use: href references "other.svg#a"`, `2 path: fill references "file.svg#b"`, `2 style: style imports "x.css"`}},
+		{"unresolved-reference", "<svg>\n" + `<g id="a"/><use href="#a"/><path fill="url(#b)"/><style>a { fill: url(#c) }</style></svg>`,
+			[]string{"2 path: reference to missing #b", "2 style: reference to missing #c"}},
+		{"duplicate-id", "<svg id=\"a\">\n" + `<g id="a"/><g id="b"/></svg>`,
+			[]string{`2 g#a: duplicate id "a", first used on line 1`}},
+		{"font", "<svg style=\"font-family:'Comic Sans', serif\">\n" + `<text>a<tspan font-family="Go">b</tspan></text><text>c</text><text font-family="sans-serif">d</text><text font-family="Other"> </text></svg>`,
+			[]string{`2 text: text uses font "Comic Sans"`, `2 text: text uses font "Go"`}},
+		{"affinity", "<!--?xml version=\"1.0\"?--><svg viewbox=\"0 0 1 1\">\n" + `<clippath/><linearGradient id="a"><stop/></linearGradient><path style="visibility:visible;fill:url(#a)"/><flowRoot/></svg>`,
+			[]string{"0 : XML declaration inside a comment (restore-prolog)", "1 svg: lowercased attribute viewBox (restore-case)", "2 clippath: lowercased element clipPath (restore-case)", "2 path: visibility:visible (remove-visibility)", "2 path: fill uses single color gradient #a (inline-gradients)", "2 flowRoot: SVG 1.2 flowed text, convert it to regular text"}},
+	}
+	for _, test := range tests {
+		doc, err := ParseString(test.in)
+		if err != nil {
+			t.Fatalf("%s: %v", test.rule, err)
+		}
+		var got []string
+		for _, problem := range Lint(doc, []*Rule{LookupRule(test.rule)}) {
+			got = append(got, fmt.Sprintf("%d %s: %s", problem.Line, problem.Element, problem.Message))
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: %s\ngot  %q\nwant %q", test.rule, test.in, got, test.want)
+		}
+	}
+}
+
+func TestSelectRules(t *testing.T) {
+	rules, err := SelectRules("all,-font,-affinity")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != len(Rules)-2 {
+		t.Errorf("got %d rules", len(rules))
+	}
+	for _, rule := range rules {
+		if rule == FontRule || rule == AffinityRule {
+			t.Errorf("%s should be excluded", rule.Name)
+		}
+	}
+
+	if _, err := SelectRules("all,unknown"); err == nil {
+		t.Errorf("expected an error for an unknown rule")
+	}
+}
@@ -0,0 +1,592 @@
+package svg
+
+import (
+	"strconv"
+	"strings"
+)
+
+// transformAttrs are the attributes containing a transform list.
+var transformAttrs = []string{"transform", "gradientTransform", "patternTransform"}
+
+// lengthAttrs are the attributes containing a single length.
+var lengthAttrs = []string{
+	"x", "y", "width", "height",
+	"cx", "cy", "r", "rx", "ry", "fx", "fy",
+	"x1", "y1", "x2", "y2",
+	"stroke-width",
+}
+
+// hasURL reports whether node references other elements
+// from its attributes, e.g. for a fill or a clip-path.
+func hasURL(node *Node) bool {
+	for _, attr := range node.Attrs {
+		if strings.Contains(attr.Value, "url(") {
+			return true
+		}
+	}
+	return false
+}
+
+// inherited returns property when node takes it from an ancestor,
+// an inherited "none" is treated as missing.
+func inherited(node *Node, property string) (string, bool) {
+	if value, ok := node.Property(property); ok && value != "inherit" {
+		return "", false
+	}
+	value, ok := node.Inherited(property)
+	if !ok || strings.TrimSpace(value) == "none" {
+		return "", false
+	}
+	return value, true
+}
+
+// inheritsPaintServer reports whether node is filled or stroked with
+// a gradient or pattern of an ancestor. The paint server depends on
+// the user space or the bounding box of node.
+func inheritsPaintServer(node *Node) bool {
+	for _, property := range []string{"fill", "stroke"} {
+		if value, ok := inherited(node, property); ok && strings.Contains(value, "url(") {
+			return true
+		}
+	}
+	return false
+}
+
+// CollapseTransformsPass removes identity transforms, replaces transform
+// lists by the shortest equivalent, moves group transforms into a single
+// child and applies translations directly to coordinates.
+var CollapseTransformsPass = &Pass{
+	Name:        "collapse-transforms",
+	Description: "remove redundant transforms and apply translations to coordinates",
+	Run: func(doc *Document, opts *Options, report *Report) {
+		root := doc.Root()
+		if root == nil {
+			return
+		}
+
+		// groups, which only exist to transform a single element
+		refs := doc.References()
+		unwrapped := 0
+		var unwrap func(node *Node)
+		unwrap = func(node *Node) {
+			for _, child := range node.Elements() {
+				unwrap(child)
+			}
+			if node.Parent == nil || !node.Is("g") || !onlyTransform(node) {
+				return
+			}
+			var only *Node
+			for _, child := range node.Children {
+				switch {
+				case child.IsSpace():
+				case child.Kind == Element && only == nil:
+					only = child
+				default:
+					return
+				}
+			}
+			// a use element would lose the transform of the group
+			if only == nil || refs[only.ID()] && only.ID() != "" {
+				return
+			}
+
+			outer, err := ParseTransform(node.Get("transform"))
+			if err != nil {
+				return
+			}
+			inner, err := ParseTransform(only.Get("transform"))
+			if err != nil {
+				return
+			}
+			setTransform(only, "transform", outer.Mul(inner))
+
+			node.RemoveChild(only)
+			node.Parent.InsertBefore(only, node)
+			node.Parent.RemoveChild(node)
+			unwrapped++
+		}
+		unwrap(root)
+		if unwrapped > 0 {
+			report.Changef("unwrapped %d groups", unwrapped)
+		}
+
+		applied, shortened := 0, 0
+		doc.Walk(func(node *Node) bool {
+			if node.Kind != Element {
+				return true
+			}
+
+			if node.Has("transform") && !hasURL(node) {
+				m, err := ParseTransform(node.Get("transform"))
+				if err == nil && m.IsTranslation() && translate(node, m[4], m[5]) {
+					node.Remove("transform")
+					applied++
+				}
+			}
+
+			for _, name := range transformAttrs {
+				value := node.Get(name)
+				if value == "" {
+					continue
+				}
+				m, err := ParseTransform(value)
+				if err != nil {
+					continue
+				}
+				if formatted := m.Format(-1); len(formatted) < len(value) {
+					setTransform(node, name, m)
+					shortened++
+				}
+			}
+			return true
+		})
+		if applied > 0 {
+			report.Changef("applied %d translations to coordinates", applied)
+		}
+		if shortened > 0 {
+			report.Changef("shortened %d transforms", shortened)
+		}
+	},
+}
+
+// onlyTransform reports whether node has no attributes except a transform.
+func onlyTransform(node *Node) bool {
+	for _, attr := range node.Attrs {
+		if attr.Name != "transform" {
+			return false
+		}
+	}
+	return true
+}
+
+// setTransform sets the attribute name to m, removing it for the identity.
+func setTransform(node *Node, name string, m Matrix) {
+	if formatted := m.Format(-1); formatted != "" {
+		node.Set(name, formatted)
+	} else {
+		node.Remove(name)
+	}
+}
+
+// translate moves the coordinates of a shape by dx, dy,
+// only when the result is shorter than keeping the transform.
+func translate(node *Node, dx, dy float64) bool {
+	// a userSpaceOnUse gradient would stay behind
+	if inheritsPaintServer(node) {
+		return false
+	}
+	transform := node.Get("transform")
+
+	// the new values for the attributes
+	values := map[string]string{}
+	move := func(name string, delta float64) bool {
+		v, err := strconv.ParseFloat(node.Get(name), 64)
+		if err != nil {
+			return false
+		}
+		values[name] = formatNumber(v+delta, decimalsOf(transform, node.Get(name)))
+		return true
+	}
+
+	switch Local(node.Name) {
+	case "path":
+		d := node.Get("d")
+		path, err := ParsePath(d)
+		if err != nil {
+			return false
+		}
+		path.Translate(dx, dy)
+		values["d"] = path.Format(decimalsOf(transform, d))
+	case "rect", "image", "use":
+		if !move("x", dx) || !move("y", dy) {
+			return false
+		}
+	case "circle", "ellipse":
+		if !move("cx", dx) || !move("cy", dy) {
+			return false
+		}
+	case "line":
+		if !move("x1", dx) || !move("y1", dy) || !move("x2", dx) || !move("y2", dy) {
+			return false
+		}
+	default:
+		return false
+	}
+
+	before := len(" transform=\"\"") + len(transform)
+	after := 0
+	for name, value := range values {
+		before += len(node.Get(name))
+		after += len(value)
+	}
+	if after >= before {
+		return false
+	}
+	for name, value := range values {
+		node.Set(name, value)
+	}
+	return true
+}
+
+// decimalsOf returns the number of decimals needed to keep
+// the precision of all values.
+func decimalsOf(values ...string) int {
+	decimals := 0
+	for _, value := range values {
+		n := maxDecimals(value)
+		if n < 0 {
+			return -1
+		}
+		if n > decimals {
+			decimals = n
+		}
+	}
+	return decimals
+}
+
+// RoundNumbersPass rounds coordinates, lengths and transforms
+// to Options.Precision decimals.
+var RoundNumbersPass = &Pass{
+	Name:        "round-numbers",
+	Description: "round coordinates and lengths, see -precision",
+	Run: func(doc *Document, opts *Options, report *Report) {
+		decimals := opts.Precision
+
+		rounded := map[string]int{}
+		doc.Walk(func(node *Node) bool {
+			if node.Kind != Element {
+				return true
+			}
+
+			var attrs []string
+			for _, attr := range node.Attrs {
+				attrs = append(attrs, attr.Name)
+			}
+			for _, name := range attrs {
+				value := node.Get(name)
+
+				var result string
+				switch {
+				case name == "d" && node.Is("path"):
+					path, err := ParsePath(value)
+					if err != nil {
+						continue
+					}
+					path.Round(decimals)
+					result = path.Format(decimals)
+				case name == "points":
+					xs, err := parseNumbers(value)
+					if err != nil {
+						continue
+					}
+					formatted := make([]string, len(xs))
+					for i, x := range xs {
+						formatted[i] = formatNumber(x, decimals)
+					}
+					result = joinNumbers(formatted...)
+				case contains(transformAttrs, name):
+					m, err := ParseTransform(value)
+					if err != nil {
+						continue
+					}
+					result = m.Format(decimals)
+					if result == "" {
+						node.Remove(name)
+						rounded[name]++
+						continue
+					}
+				case contains(lengthAttrs, name):
+					result = roundLength(value, decimals)
+				case name == "style":
+					style := node.Style()
+					for i, decl := range style {
+						if contains(lengthAttrs, decl.Name) {
+							style[i].Value = roundLength(decl.Value, decimals)
+						}
+					}
+					result = style.String()
+				default:
+					continue
+				}
+
+				if len(result) < len(value) {
+					node.Set(name, result)
+					rounded[name]++
+				}
+			}
+			return true
+		})
+
+		names := map[string]bool{}
+		for name := range rounded {
+			names[name] = true
+		}
+		for _, name := range sortedKeys(names) {
+			report.Changef("rounded %d %s attributes", rounded[name], name)
+		}
+	},
+}
+
+// roundLength rounds a length, such as "12.3456px".
+func roundLength(value string, decimals int) string {
+	value = strings.TrimSpace(value)
+	end := len(value)
+	for end > 0 && (value[end-1] == '%' || 'a' <= value[end-1] && value[end-1] <= 'z') {
+		end--
+	}
+	v, err := strconv.ParseFloat(value[:end], 64)
+	if err != nil {
+		return value
+	}
+	return formatNumber(v, decimals) + value[end:]
+}
+
+// MinifyPathsPass rewrites path data in the shortest form
+// without changing the precision.
+var MinifyPathsPass = &Pass{
+	Name:        "minify-paths",
+	Description: "write path data in the shortest absolute and relative form",
+	Run: func(doc *Document, opts *Options, report *Report) {
+		count, saved := 0, 0
+		doc.Walk(func(node *Node) bool {
+			if !node.Is("path") || !node.Has("d") {
+				return true
+			}
+
+			d := node.Get("d")
+			path, err := ParsePath(d)
+			if err != nil {
+				return true
+			}
+			if formatted := path.Format(maxDecimals(d)); len(formatted) < len(d) {
+				node.Set("d", formatted)
+				count++
+				saved += len(d) - len(formatted)
+			}
+			return true
+		})
+		if count > 0 {
+			report.Changef("shortened %d paths by %d bytes", count, saved)
+		}
+	},
+}
+
+// MergePathsPass merges consecutive paths with the same attributes.
+//
+// Paths are only merged when they don't overlap, since the overlapping
+// parts of a single path are drawn once.
+var MergePathsPass = &Pass{
+	Name:        "merge-paths",
+	Description: "merge consecutive non-overlapping paths with identical style",
+	Run: func(doc *Document, opts *Options, report *Report) {
+		merged := 0
+		boxes := map[*Node]Box{}
+		box := func(node *Node) (Box, bool) {
+			if box, ok := boxes[node]; ok {
+				return box, true
+			}
+			path, err := ParsePath(node.Get("d"))
+			if err != nil {
+				return Box{}, false
+			}
+			box := path.controlBox().Inset(-strokePadding(node))
+			boxes[node] = box
+			return box, true
+		}
+
+		doc.Walk(func(parent *Node) bool {
+			if parent.Kind != Element {
+				return true
+			}
+
+			var prev *Node
+			for _, child := range append([]*Node{}, parent.Children...) {
+				switch {
+				case child.IsSpace():
+					continue
+				case !mergeablePath(child):
+					prev = nil
+					continue
+				case prev == nil || !sameAttrs(prev, child):
+					prev = child
+					continue
+				}
+
+				a, ok := box(prev)
+				b, ok2 := box(child)
+				if !ok || !ok2 || a.Overlaps(b) {
+					prev = child
+					continue
+				}
+
+				first, _ := ParsePath(prev.Get("d"))
+				second, _ := ParsePath(child.Get("d"))
+				path := append(first, second...)
+				prev.Set("d", path.Format(decimalsOf(prev.Get("d"), child.Get("d"))))
+				boxes[prev] = a.Union(b)
+				parent.RemoveChild(child)
+				merged++
+			}
+			return true
+		})
+		if merged > 0 {
+			report.Changef("merged %d paths", merged)
+		}
+	},
+}
+
+// mergeablePath reports whether node is a path that can be merged with others.
+func mergeablePath(node *Node) bool {
+	if !node.Is("path") || node.Has("id") || hasURL(node) || len(node.Children) > 0 {
+		return false
+	}
+	// the inherited style may not be the same after the paths are moved
+	// or the merged bounding box would change a gradient
+	if _, ok := inherited(node, "stroke"); ok || inheritsPaintServer(node) {
+		return false
+	}
+	for _, name := range []string{"marker", "marker-start", "marker-mid", "marker-end"} {
+		if _, ok := node.Property(name); ok {
+			return false
+		}
+	}
+	return true
+}
+
+// sameAttrs reports whether a and b have the same attributes, ignoring d.
+func sameAttrs(a, b *Node) bool {
+	values := map[string]string{}
+	for _, attr := range a.Attrs {
+		if attr.Name != "d" {
+			values[attr.Name] = attr.Value
+		}
+	}
+	n := 0
+	for _, attr := range b.Attrs {
+		if attr.Name == "d" {
+			continue
+		}
+		value, ok := values[attr.Name]
+		if !ok || value != attr.Value {
+			return false
+		}
+		n++
+	}
+	return n == len(values)
+}
+
+// strokePadding returns how far the stroke of node may reach
+// outside of its geometry.
+func strokePadding(node *Node) float64 {
+	if stroke, ok := node.Inherited("stroke"); !ok || strings.TrimSpace(stroke) == "none" {
+		return 0
+	}
+	width := 1.0
+	if value, ok := node.Inherited("stroke-width"); ok {
+		if v, err := strconv.ParseFloat(strings.TrimSuffix(value, "px"), 64); err == nil {
+			width = v
+		}
+	}
+	// miter joins may reach further than half the width
+	miter := 4.0
+	if value, ok := node.Inherited("stroke-miterlimit"); ok {
+		if v, err := strconv.ParseFloat(value, 64); err == nil {
+			miter = v
+		}
+	}
+	return width / 2 * miter
+}
+
+// presentationAttrs are the properties, which can be written as attributes.
+var presentationAttrs = map[string]bool{
+	"alignment-baseline": true, "baseline-shift": true,
+	"clip-path": true, "clip-rule": true, "color": true,
+	"color-interpolation": true, "color-interpolation-filters": true,
+	"color-profile": true, "color-rendering": true, "cursor": true,
+	"direction": true, "display": true, "dominant-baseline": true,
+	"enable-background": true, "fill": true, "fill-opacity": true,
+	"fill-rule": true, "filter": true, "flood-color": true,
+	"flood-opacity": true, "font-family": true, "font-size": true,
+	"font-size-adjust": true, "font-stretch": true, "font-style": true,
+	"font-variant": true, "font-weight": true,
+	"glyph-orientation-horizontal": true, "glyph-orientation-vertical": true,
+	"image-rendering": true, "kerning": true, "letter-spacing": true,
+	"lighting-color": true, "marker-end": true, "marker-mid": true,
+	"marker-start": true, "mask": true, "opacity": true, "overflow": true,
+	"pointer-events": true, "shape-rendering": true, "stop-color": true,
+	"stop-opacity": true, "stroke": true, "stroke-dasharray": true,
+	"stroke-dashoffset": true, "stroke-linecap": true,
+	"stroke-linejoin": true, "stroke-miterlimit": true,
+	"stroke-opacity": true, "stroke-width": true, "text-anchor": true,
+	"text-decoration": true, "text-rendering": true, "unicode-bidi": true,
+	"visibility": true, "word-spacing": true, "writing-mode": true,
+}
+
+// StyleToAttributesPass moves style properties into presentation
+// attributes, when the result is shorter.
+//
+// Documents with style sheets are left alone, since presentation
+// attributes have a lower priority than the rules in a style sheet.
+var StyleToAttributesPass = &Pass{
+	Name:        "style-to-attributes",
+	Description: "move style properties to attributes where shorter",
+	Run: func(doc *Document, opts *Options, report *Report) {
+		if len(doc.ElementsByName("style")) > 0 {
+			return
+		}
+
+		moved := 0
+		doc.Walk(func(node *Node) bool {
+			if node.Kind != Element || !node.Has("style") {
+				return true
+			}
+
+			var keep, move Style
+			for _, decl := range node.Style() {
+				if presentationAttrs[decl.Name] && !strings.Contains(decl.Value, "!important") {
+					move = append(move, decl)
+				} else {
+					keep = append(keep, decl)
+				}
+			}
+			if len(move) == 0 {
+				return true
+			}
+
+			before := len(` style=""`) + len(node.Get("style"))
+			after := 0
+			if len(keep) > 0 {
+				after += len(` style=""`) + len(keep.String())
+			}
+			for _, decl := range move {
+				if attr := node.Attr(decl.Name); attr != nil {
+					before += len(` =""`) + len(attr.Name) + len(attr.Value)
+				}
+				after += len(` =""`) + len(decl.Name) + len(decl.Value)
+			}
+			if after >= before {
+				return true
+			}
+
+			node.SetStyle(keep)
+			for _, decl := range move {
+				node.Set(decl.Name, decl.Value)
+			}
+			moved++
+			return true
+		})
+		if moved > 0 {
+			report.Changef("moved styles to attributes on %d elements", moved)
+		}
+	},
+}
+
+// contains reports whether xs contains x.
+func contains(xs []string, x string) bool {
+	for _, v := range xs {
+		if v == x {
+			return true
+		}
+	}
+	return false
+}
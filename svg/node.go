@@ -0,0 +1,344 @@
+// Package svg implements a lossless XML tree for editing SVG files.
+//
+// Unlike encoding/xml and golang.org/x/net/html the tree keeps names,
+// namespace prefixes, attribute order, quoting and whitespace exactly
+// as written, so rendering an unmodified document reproduces the
+// original bytes.
+package svg
+
+import (
+	"strings"
+)
+
+// Kind is the type of a Node.
+type Kind int
+
+const (
+	Element Kind = iota
+	Text
+	Comment
+	ProcInst
+	Directive
+	CData
+)
+
+// Document is a parsed XML file.
+type Document struct {
+	// Nodes are the top-level nodes, such as the XML declaration,
+	// comments, whitespace and the root element.
+	Nodes []*Node
+}
+
+// Root returns the first top-level element.
+func (doc *Document) Root() *Node {
+	for _, node := range doc.Nodes {
+		if node.Kind == Element {
+			return node
+		}
+	}
+	return nil
+}
+
+// Node is an element or a piece of character data.
+type Node struct {
+	Kind   Kind
+	Parent *Node
+
+	// Name is the qualified name of an element as written, e.g. "inkscape:label".
+	Name     string
+	Attrs    []*Attr
+	Children []*Node
+
+	// SelfClosing is set for elements written as <name/>.
+	SelfClosing bool
+	// TagSpace is the whitespace before ">" or "/>" of the start tag.
+	TagSpace string
+	// EndSpace is the whitespace before ">" of the end tag.
+	EndSpace string
+
+	// Raw is the content of non-element nodes as written,
+	// without the surrounding markup, e.g. "<!--" and "-->".
+	Raw string
+
+	// Line is the line of the start tag in the parsed source,
+	// it's 0 for created elements.
+	Line int
+}
+
+// Attr is an attribute of an element.
+type Attr struct {
+	// Space is the whitespace before the attribute.
+	Space string
+	// Name is the qualified name as written, e.g. "xlink:href".
+	Name string
+	// Eq is the text between the name and the value, usually "=".
+	Eq    string
+	Quote byte
+	// Value is the unescaped value.
+	Value string
+
+	// raw is the escaped value as written, used while Value is unchanged.
+	raw      string
+	rawValue string
+}
+
+// NewElement creates an element.
+func NewElement(name string) *Node {
+	return &Node{Kind: Element, Name: name}
+}
+
+// NewText creates a text node from unescaped text.
+func NewText(text string) *Node {
+	return &Node{Kind: Text, Raw: EscapeText(text)}
+}
+
+// Local returns name without the namespace prefix.
+func Local(name string) string {
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// Prefix returns the namespace prefix of name.
+func Prefix(name string) string {
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		return name[:i]
+	}
+	return ""
+}
+
+// Is reports whether node is an SVG element with the local name,
+// the element may use the "svg" prefix.
+func (node *Node) Is(local string) bool {
+	if node == nil || node.Kind != Element {
+		return false
+	}
+	prefix := Prefix(node.Name)
+	return (prefix == "" || prefix == "svg") && Local(node.Name) == local
+}
+
+// Text returns the unescaped content of a text or CDATA node,
+// for elements it returns the concatenated text of all descendants.
+func (node *Node) Text() string {
+	switch node.Kind {
+	case Text:
+		return UnescapeText(node.Raw)
+	case CData:
+		return node.Raw
+	case Element:
+		var b strings.Builder
+		for _, child := range node.Children {
+			if child.Kind == Element || child.Kind == Text || child.Kind == CData {
+				b.WriteString(child.Text())
+			}
+		}
+		return b.String()
+	}
+	return ""
+}
+
+// Attr returns the attribute with the qualified name.
+func (node *Node) Attr(name string) *Attr {
+	for _, attr := range node.Attrs {
+		if attr.Name == name {
+			return attr
+		}
+	}
+	return nil
+}
+
+// Get returns the value of attribute name or "".
+func (node *Node) Get(name string) string {
+	if attr := node.Attr(name); attr != nil {
+		return attr.Value
+	}
+	return ""
+}
+
+// Has reports whether the attribute exists.
+func (node *Node) Has(name string) bool {
+	return node.Attr(name) != nil
+}
+
+// Inherited returns the value of a property from node or its ancestors.
+func (node *Node) Inherited(name string) (string, bool) {
+	for n := node; n != nil; n = n.Parent {
+		if value, ok := n.Property(name); ok && value != "inherit" {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// Set sets the value of attribute name, appending it when missing.
+func (node *Node) Set(name, value string) {
+	if attr := node.Attr(name); attr != nil {
+		attr.Value = value
+		return
+	}
+
+	space := " "
+	if len(node.Attrs) > 0 {
+		space = node.Attrs[len(node.Attrs)-1].Space
+	}
+	node.Attrs = append(node.Attrs, &Attr{
+		Space: space,
+		Name:  name,
+		Eq:    "=",
+		Quote: '"',
+		Value: value,
+	})
+}
+
+// Remove removes attribute name and reports whether it existed.
+func (node *Node) Remove(name string) bool {
+	for i, attr := range node.Attrs {
+		if attr.Name == name {
+			if i == 0 && len(node.Attrs) > 1 {
+				// keep the formatting of the first attribute
+				node.Attrs[1].Space = attr.Space
+			}
+			node.Attrs = append(node.Attrs[:i], node.Attrs[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Href returns the value of href or xlink:href.
+func (node *Node) Href() string {
+	if attr := node.Attr("href"); attr != nil {
+		return attr.Value
+	}
+	for _, attr := range node.Attrs {
+		if Local(attr.Name) == "href" {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// ID returns the id attribute.
+func (node *Node) ID() string { return node.Get("id") }
+
+// AppendChild adds child as the last child of node.
+func (node *Node) AppendChild(child *Node) {
+	child.Parent = node
+	node.Children = append(node.Children, child)
+	node.SelfClosing = false
+}
+
+// InsertBefore inserts child before the existing child ref.
+func (node *Node) InsertBefore(child, ref *Node) {
+	for i, c := range node.Children {
+		if c == ref {
+			child.Parent = node
+			node.Children = append(node.Children[:i], append([]*Node{child}, node.Children[i:]...)...)
+			return
+		}
+	}
+	node.AppendChild(child)
+}
+
+// RemoveChild removes child from node. The whitespace text before
+// the child is removed as well, to keep the indentation consistent.
+func (node *Node) RemoveChild(child *Node) {
+	for i, c := range node.Children {
+		if c != child {
+			continue
+		}
+
+		start := i
+		if i > 0 && node.Children[i-1].IsSpace() {
+			start = i - 1
+		}
+		node.Children = append(node.Children[:start], node.Children[i+1:]...)
+		child.Parent = nil
+		return
+	}
+}
+
+// Detach removes node from its parent.
+func (node *Node) Detach() {
+	if node.Parent != nil {
+		node.Parent.RemoveChild(node)
+	}
+}
+
+// IsSpace reports whether node is whitespace only text.
+func (node *Node) IsSpace() bool {
+	return node.Kind == Text && strings.TrimSpace(node.Raw) == ""
+}
+
+// Elements returns the child elements of node.
+func (node *Node) Elements() []*Node {
+	var xs []*Node
+	for _, child := range node.Children {
+		if child.Kind == Element {
+			xs = append(xs, child)
+		}
+	}
+	return xs
+}
+
+// Walk calls fn for node and all its descendants in document order,
+// when fn returns false the children of that node are skipped.
+func (node *Node) Walk(fn func(*Node) bool) {
+	if !fn(node) {
+		return
+	}
+	// copy, so that fn may modify the children
+	children := append([]*Node{}, node.Children...)
+	for _, child := range children {
+		child.Walk(fn)
+	}
+}
+
+// Walk calls fn for every node in the document.
+func (doc *Document) Walk(fn func(*Node) bool) {
+	nodes := append([]*Node{}, doc.Nodes...)
+	for _, node := range nodes {
+		node.Walk(fn)
+	}
+}
+
+// ElementsByName returns all SVG elements with the local name.
+func (doc *Document) ElementsByName(local string) []*Node {
+	var xs []*Node
+	doc.Walk(func(node *Node) bool {
+		if node.Is(local) {
+			xs = append(xs, node)
+		}
+		return true
+	})
+	return xs
+}
+
+// ElementsByName returns all descendant SVG elements with the local name.
+func (node *Node) ElementsByName(local string) []*Node {
+	var xs []*Node
+	node.Walk(func(n *Node) bool {
+		if n != node && n.Is(local) {
+			xs = append(xs, n)
+		}
+		return true
+	})
+	return xs
+}
+
+// IDs returns an index of all elements with an id attribute.
+func (doc *Document) IDs() map[string]*Node {
+	ids := map[string]*Node{}
+	doc.Walk(func(node *Node) bool {
+		if node.Kind == Element {
+			if id := node.ID(); id != "" {
+				if _, exists := ids[id]; !exists {
+					ids[id] = node
+				}
+			}
+		}
+		return true
+	})
+	return ids
+}
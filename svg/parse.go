@@ -0,0 +1,260 @@
+package svg
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// SyntaxError is returned for malformed documents.
+type SyntaxError struct {
+	Line int
+	Msg  string
+}
+
+func (err *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d: %s", err.Line, err.Msg)
+}
+
+// Parse reads a document from r.
+func Parse(r io.Reader) (*Document, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return ParseString(string(data))
+}
+
+// ParseString parses a document.
+func ParseString(s string) (*Document, error) {
+	p := &parser{src: s}
+	doc := &Document{}
+
+	var current *Node
+	add := func(node *Node) {
+		if current == nil {
+			doc.Nodes = append(doc.Nodes, node)
+		} else {
+			current.AppendChild(node)
+		}
+	}
+
+	for p.pos < len(p.src) {
+		if p.src[p.pos] != '<' {
+			end := strings.IndexByte(p.src[p.pos:], '<')
+			if end < 0 {
+				end = len(p.src) - p.pos
+			}
+			add(&Node{Kind: Text, Raw: p.src[p.pos : p.pos+end]})
+			p.pos += end
+			continue
+		}
+
+		rest := p.src[p.pos:]
+		switch {
+		case strings.HasPrefix(rest, "<?"):
+			raw, err := p.until("<?", "?>")
+			if err != nil {
+				return nil, err
+			}
+			add(&Node{Kind: ProcInst, Raw: raw})
+		case strings.HasPrefix(rest, "<!--"):
+			raw, err := p.until("<!--", "-->")
+			if err != nil {
+				return nil, err
+			}
+			add(&Node{Kind: Comment, Raw: raw})
+		case strings.HasPrefix(rest, "<![CDATA["):
+			raw, err := p.until("<![CDATA[", "]]>")
+			if err != nil {
+				return nil, err
+			}
+			add(&Node{Kind: CData, Raw: raw})
+		case strings.HasPrefix(rest, "<!"):
+			raw, err := p.directive()
+			if err != nil {
+				return nil, err
+			}
+			add(&Node{Kind: Directive, Raw: raw})
+		case strings.HasPrefix(rest, "</"):
+			p.pos += 2
+			name := p.name()
+			space := p.space()
+			if !p.consume(">") {
+				return nil, p.errorf("expected > in end tag of %q", name)
+			}
+			if current == nil || current.Name != name {
+				return nil, p.errorf("unexpected end tag %q", name)
+			}
+			current.EndSpace = space
+			current = current.Parent
+		default:
+			line := p.line()
+			node, err := p.startTag()
+			if err != nil {
+				return nil, err
+			}
+			node.Line = line
+			add(node)
+			if !node.SelfClosing {
+				current = node
+			}
+		}
+	}
+
+	if current != nil {
+		return nil, p.errorf("unclosed element %q", current.Name)
+	}
+	return doc, nil
+}
+
+type parser struct {
+	src string
+	pos int
+
+	// lines counted up to linePos
+	lines   int
+	linePos int
+}
+
+// line returns the line number at the current position.
+func (p *parser) line() int {
+	p.lines += strings.Count(p.src[p.linePos:p.pos], "\n")
+	p.linePos = p.pos
+	return p.lines + 1
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{
+		Line: strings.Count(p.src[:p.pos], "\n") + 1,
+		Msg:  fmt.Sprintf(format, args...),
+	}
+}
+
+func (p *parser) consume(s string) bool {
+	if strings.HasPrefix(p.src[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+// until returns the content between open and close.
+func (p *parser) until(open, close string) (string, error) {
+	start := p.pos + len(open)
+	end := strings.Index(p.src[start:], close)
+	if end < 0 {
+		return "", p.errorf("missing %q", close)
+	}
+	p.pos = start + end + len(close)
+	return p.src[start : start+end], nil
+}
+
+// directive parses <!...> with an optional internal subset in brackets.
+func (p *parser) directive() (string, error) {
+	start := p.pos + 2
+	depth := 0
+	var quote byte
+	for i := start; i < len(p.src); i++ {
+		c := p.src[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == '>' && depth <= 0:
+			p.pos = i + 1
+			return p.src[start:i], nil
+		}
+	}
+	return "", p.errorf("unterminated directive")
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func (p *parser) space() string {
+	start := p.pos
+	for p.pos < len(p.src) && isSpace(p.src[p.pos]) {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *parser) name() string {
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if isSpace(c) || c == '=' || c == '/' || c == '>' || c == '<' || c == '"' || c == '\'' {
+			break
+		}
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *parser) startTag() (*Node, error) {
+	p.pos++ // <
+	node := &Node{Kind: Element, Name: p.name()}
+	if node.Name == "" {
+		return nil, p.errorf("expected element name")
+	}
+
+	for {
+		space := p.space()
+		if p.pos >= len(p.src) {
+			return nil, p.errorf("unterminated start tag %q", node.Name)
+		}
+
+		switch {
+		case p.consume("/>"):
+			node.TagSpace = space
+			node.SelfClosing = true
+			return node, nil
+		case p.consume(">"):
+			node.TagSpace = space
+			return node, nil
+		}
+
+		if space == "" && len(node.Attrs) > 0 {
+			return nil, p.errorf("expected space between attributes of %q", node.Name)
+		}
+
+		attr := &Attr{Space: space, Name: p.name()}
+		if attr.Name == "" {
+			return nil, p.errorf("invalid attribute in %q", node.Name)
+		}
+
+		eqStart := p.pos
+		p.space()
+		if !p.consume("=") {
+			return nil, p.errorf("expected = after attribute %q", attr.Name)
+		}
+		p.space()
+		attr.Eq = p.src[eqStart:p.pos]
+
+		if p.pos >= len(p.src) || (p.src[p.pos] != '"' && p.src[p.pos] != '\'') {
+			return nil, p.errorf("expected quoted value for attribute %q", attr.Name)
+		}
+		attr.Quote = p.src[p.pos]
+		p.pos++
+		end := strings.IndexByte(p.src[p.pos:], attr.Quote)
+		if end < 0 {
+			return nil, p.errorf("unterminated value of attribute %q", attr.Name)
+		}
+		attr.raw = p.src[p.pos : p.pos+end]
+		attr.Value = UnescapeText(attr.raw)
+		attr.rawValue = attr.Value
+		p.pos += end + 1
+
+		node.Attrs = append(node.Attrs, attr)
+	}
+}
@@ -0,0 +1,98 @@
+package svg
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	tests := []string{
+		`<?xml version="1.0" encoding="UTF-8" standalone="no"?>` + "\n<svg/>\n",
+		`<!DOCTYPE svg PUBLIC "-//W3C//DTD SVG 1.1//EN" [<!ENTITY a "b>c">]><svg></svg>`,
+		`<svg  xmlns:xlink = 'http://www.w3.org/1999/xlink'` + "\n\t" + `id="a" ><use xlink:href="#a" /></svg >`,
+		`<svg><!-- <g> --><style><![CDATA[ a > b { fill: red } ]]></style></svg>`,
+		`<svg><text title="a &amp; b &#10;&quot;" x='&apos;'>1 &lt; 2 &#x263A;</text></svg>`,
+		"<svg>\r\n  <g>\r\n  </g>\r\n</svg>",
+	}
+	for _, src := range tests {
+		doc, err := ParseString(src)
+		if err != nil {
+			t.Errorf("%q: %v", src, err)
+			continue
+		}
+		if got := string(doc.Bytes()); got != src {
+			t.Errorf("got  %q\nwant %q", got, src)
+		}
+	}
+}
+
+func TestRoundTripFiles(t *testing.T) {
+	files, err := filepath.Glob("../vector/*/*.svg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Skip("no svg files")
+	}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc, err := ParseString(string(data))
+		if err != nil {
+			t.Errorf("%s: %v", file, err)
+			continue
+		}
+		if got := doc.Bytes(); string(got) != string(data) {
+			t.Errorf("%s: changed when rendered", file)
+		}
+	}
+}
+
+func TestModify(t *testing.T) {
+	doc, err := ParseString(`<svg a='1'  b="&amp;"><g/></svg>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := doc.Root()
+	if root.Get("b") != "&" {
+		t.Errorf("got b=%q", root.Get("b"))
+	}
+
+	root.Set("a", `it's "quoted"`)
+	root.Remove("b")
+	root.Set("c", "x\ny")
+	root.Elements()[0].AppendChild(NewText("a < b"))
+
+	want := `<svg a='it&apos;s "quoted"' c="x&#10;y"><g>a &lt; b</g></svg>`
+	if got := string(doc.Bytes()); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		line int
+	}{
+		{"<svg>\n<g>\n</svg>", 3},
+		{"<svg>\n<g a=1/>\n</svg>", 2},
+		{"<svg>\n\n<g a='1'b='2'/></svg>", 3},
+		{"<svg>\n<!-- open", 2},
+		{"<svg>\n<g>", 2},
+		{"<svg a='1", 1},
+	}
+	for _, test := range tests {
+		_, err := ParseString(test.src)
+		syntax, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("%q: expected a syntax error, got %v", test.src, err)
+			continue
+		}
+		if syntax.Line != test.line {
+			t.Errorf("%q: got %v, expected line %d", test.src, err, test.line)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package svg
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/image/font/sfnt"
+)
+
+// Pass is a named transformation of a document.
+type Pass struct {
+	Name        string
+	Description string
+	// Default passes run when no passes are explicitly selected.
+	Default bool
+	Run     func(doc *Document, opts *Options, report *Report)
+}
+
+// Options configures the passes.
+type Options struct {
+	// KeepLayerNames keeps Inkscape layer names when stripping editor data.
+	KeepLayerNames bool
+	// Precision is the number of decimals kept by round-numbers.
+	Precision int
+	// Fonts are used by text-to-path, the first font is used
+	// for families without a matching font.
+	Fonts []*sfnt.Font
+
+	// inlined are the gradients replaced by inline-gradients,
+	// which remove-dead-gradients may remove.
+	inlined map[string]bool
+}
+
+// Report collects the changes made by a pass.
+type Report struct {
+	Changes []string
+}
+
+// Changef records a change.
+func (report *Report) Changef(format string, args ...interface{}) {
+	report.Changes = append(report.Changes, fmt.Sprintf(format, args...))
+}
+
+// Changed reports whether any change was recorded.
+func (report *Report) Changed() bool { return len(report.Changes) > 0 }
+
+// Passes lists all passes in the order they are run.
+var Passes = []*Pass{
+	RestorePrologPass,
+	RestoreCasePass,
+	RemoveVisibilityPass,
+	InlineGradientsPass,
+	RemoveDeadGradientsPass,
+	RemoveUnusedGradientsPass,
+	StripEditorDataPass,
+	CollapseTransformsPass,
+	RoundNumbersPass,
+	MergePathsPass,
+	MinifyPathsPass,
+	StyleToAttributesPass,
+	TextToPathPass,
+}
+
+// LookupPass finds a pass by name.
+func LookupPass(name string) *Pass {
+	for _, pass := range Passes {
+		if pass.Name == name {
+			return pass
+		}
+	}
+	return nil
+}
+
+// SelectPasses parses a comma separated list of pass names.
+// "default" and "all" select groups of passes and a name
+// prefixed with "-" removes the pass from the selection.
+// The result is in the order of Passes.
+func SelectPasses(spec string) ([]*Pass, error) {
+	selected := map[*Pass]bool{}
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		enable := !strings.HasPrefix(name, "-")
+		name = strings.TrimPrefix(name, "-")
+
+		switch name {
+		case "":
+		case "default", "all":
+			for _, pass := range Passes {
+				if name == "all" || pass.Default {
+					selected[pass] = enable
+				}
+			}
+		default:
+			pass := LookupPass(name)
+			if pass == nil {
+				return nil, fmt.Errorf("unknown pass %q", name)
+			}
+			selected[pass] = enable
+		}
+	}
+
+	var passes []*Pass
+	for _, pass := range Passes {
+		if selected[pass] {
+			passes = append(passes, pass)
+		}
+	}
+	return passes, nil
+}
+
+// Result is the outcome of running a single pass.
+type Result struct {
+	Pass   *Pass
+	Report Report
+}
+
+// Run runs passes on doc and returns a result per pass.
+func Run(doc *Document, passes []*Pass, opts *Options) []Result {
+	state := Options{}
+	if opts != nil {
+		state = *opts
+	}
+	// passes share state about a single document
+	state.inlined = map[string]bool{}
+
+	results := make([]Result, len(passes))
+	for i, pass := range passes {
+		results[i].Pass = pass
+		pass.Run(doc, &state, &results[i].Report)
+	}
+	return results
+}
+
+// sortedKeys returns the keys of a set in sorted order.
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
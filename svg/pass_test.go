@@ -0,0 +1,112 @@
+package svg
+
+import (
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+)
+
+func TestPasses(t *testing.T) {
+	font, err := sfnt.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		passes string
+		in     string
+		want   string
+	}{
+		{"restore-prolog",
+			`<!--?xml version="1.0"?--><svg/>`,
+			`<?xml version="1.0"?><svg/>`},
+		// the document is already declared
+		{"restore-prolog",
+			`<?xml version="1.0"?>` + "\n" + `<!--?xml version="1.0"?--><svg/>`,
+			`<?xml version="1.0"?><svg/>`},
+		// a declaration is only valid at the start
+		{"restore-prolog",
+			`<svg/>` + "\n" + `<!--?xml version="1.0"?-->`,
+			`<svg/>`},
+		// nothing to restore
+		{"restore-prolog",
+			`<?xml version="1.0"?><!-- comment --><svg/>`,
+			`<?xml version="1.0"?><!-- comment --><svg/>`},
+		{"restore-case",
+			`<svg viewbox="0 0 1 1"><lineargradient gradienttransform="scale(2)"/><x:lineargradient viewbox="1"/></svg>`,
+			`<svg viewBox="0 0 1 1"><linearGradient gradientTransform="scale(2)"/><x:lineargradient viewBox="1"/></svg>`},
+		{"remove-visibility",
+			`<svg><path style="fill:red;visibility:visible"/><path style="visibility:hidden"/></svg>`,
+			`<svg><path style="fill:red"/><path style="visibility:hidden"/></svg>`},
+		{"inline-gradients",
+			`<svg><linearGradient id="a"><stop style="stop-color:#f00;stop-opacity:0.5"/></linearGradient><path fill="url(#a)"/><path style="stroke:url(#a)"/></svg>`,
+			`<svg><linearGradient id="a"><stop style="stop-color:#f00;stop-opacity:0.5"/></linearGradient><path fill="#f00" fill-opacity=".5"/><path style="stroke:#f00;stroke-opacity:.5"/></svg>`},
+		{"inline-gradients,remove-dead-gradients",
+			`<svg><linearGradient id="base"><stop stop-color="#f00"/></linearGradient><linearGradient id="a" href="#base"/><linearGradient id="swatch"><stop/></linearGradient><path fill="url(#a)"/></svg>`,
+			`<svg><linearGradient id="swatch"><stop/></linearGradient><path fill="#f00"/></svg>`},
+		// only gradients inlined by inline-gradients are dead
+		{"remove-dead-gradients",
+			`<svg><linearGradient id="a"><stop stop-color="#f00"/></linearGradient></svg>`,
+			`<svg><linearGradient id="a"><stop stop-color="#f00"/></linearGradient></svg>`},
+		{"remove-unused-gradients",
+			`<svg><linearGradient id="base"><stop/></linearGradient><linearGradient id="a" href="#base"/><linearGradient id="used"/><path fill="url(#used)"/></svg>`,
+			`<svg><linearGradient id="used"/><path fill="url(#used)"/></svg>`},
+		{"strip-editor-data",
+			`<svg xmlns:inkscape="http://www.inkscape.org/namespaces/inkscape" xmlns:sodipodi="http://sodipodi.sourceforge.net/DTD/sodipodi-0.dtd"><metadata/><sodipodi:namedview/><g inkscape:label="Layer" inkscape:groupmode="layer"><path inkscape:connector-curvature="0" d="M0 0"/></g><g><g/></g></svg>`,
+			`<svg><g><path d="M0 0"/></g></svg>`},
+		{"collapse-transforms",
+			`<svg><g transform="translate(1,2)"><rect x="1" y="1" width="2" height="2"/></g><path transform="matrix(1,0,0,1,0,0)" d="M0 0"/><circle transform="scale(2,2)" r="1"/></svg>`,
+			`<svg><rect x="2" y="3" width="2" height="2"/><path d="M0 0"/><circle transform="scale(2)" r="1"/></svg>`},
+		// the gradient is in the user space of the rect
+		{"collapse-transforms",
+			`<svg><linearGradient id="g" gradientUnits="userSpaceOnUse"/><g fill="url(#g)"><rect transform="translate(1 2)" x="1" y="1" width="2" height="2"/></g></svg>`,
+			`<svg><linearGradient id="g" gradientUnits="userSpaceOnUse"/><g fill="url(#g)"><rect transform="translate(1 2)" x="1" y="1" width="2" height="2"/></g></svg>`},
+		{"round-numbers",
+			`<svg><path d="M0.123 0.456L1.987 2.01"/><rect x="1.25" width="3.333px" style="stroke-width:0.666"/><g transform="translate(0.04,0.01)"/></svg>`,
+			`<svg><path d="M.1.5 2 2"/><rect x="1.3" width="3.3px" style="stroke-width:.7"/><g/></svg>`},
+		{"merge-paths",
+			`<svg><path fill="red" stroke="none" d="M0 0h1v1z"/><path fill="red" stroke="none" d="M5 5h1v1z"/><path fill="red" stroke="none" d="M5.5 5.5h1v1z"/><path fill="blue" stroke="none" d="M9 9h1v1z"/></svg>`,
+			`<svg><path fill="red" stroke="none" d="M0 0H1V1zM5 5H6V6z"/><path fill="red" stroke="none" d="M5.5 5.5h1v1z"/><path fill="blue" stroke="none" d="M9 9h1v1z"/></svg>`},
+		// the stroke width is inherited
+		{"merge-paths",
+			`<svg><g stroke-width="10"><path fill="red" stroke="red" d="M0 0h1v1z"/><path fill="red" stroke="red" d="M6 6h1v1z"/></g></svg>`,
+			`<svg><g stroke-width="10"><path fill="red" stroke="red" d="M0 0h1v1z"/><path fill="red" stroke="red" d="M6 6h1v1z"/></g></svg>`},
+		// inherited strokes and paint servers
+		{"merge-paths",
+			`<svg><g stroke="red"><path fill="red" d="M0 0h1v1z"/><path fill="red" d="M6 6h1v1z"/></g><g fill="url(#g)"><path d="M0 0h1v1z"/><path d="M6 6h1v1z"/></g></svg>`,
+			`<svg><g stroke="red"><path fill="red" d="M0 0h1v1z"/><path fill="red" d="M6 6h1v1z"/></g><g fill="url(#g)"><path d="M0 0h1v1z"/><path d="M6 6h1v1z"/></g></svg>`},
+		{"minify-paths",
+			`<svg><path d="M 10.5,10 L 20,10 L 20,20 L 10.5,20 Z"/></svg>`,
+			`<svg><path d="M10.5 10H20V20H10.5z"/></svg>`},
+		{"style-to-attributes",
+			`<svg><path style="fill:red"/><path style="fill:red;stroke:blue"/><path style="fill:red;-inkscape-font-specification:x"/><path style="fill:red!important"/></svg>`,
+			`<svg><path fill="red"/><path fill="red" stroke="blue"/><path style="fill:red;-inkscape-font-specification:x"/><path style="fill:red!important"/></svg>`},
+		// attributes would lose to the style sheet
+		{"style-to-attributes",
+			`<svg><style>path{fill:blue}</style><path style="fill:red"/></svg>`,
+			`<svg><style>path{fill:blue}</style><path style="fill:red"/></svg>`},
+		{"text-to-path",
+			`<svg><text id="t" x="1" y="2" style="font-size:2px;fill:red"><tspan x="1" y="2">l</tspan></text></svg>`,
+			`<svg><g id="t" style="font-size:2px;fill:red"><path d="M1.343 1.672q0 .056.01.096.009.039.031.064.022.024.054.036.032.012.073.011v.14q-.022.005-.059.005-.063 0-.121-.021-.057-.022-.098-.062-.04-.041-.061-.099-.022-.06-.022-.135V.458h.193V1.672z"></path></g></svg>`},
+		// nothing to change
+		{"all",
+			`<svg viewBox="0 0 1 1"><path fill="red" d="M0 0h1v1z"/></svg>`,
+			`<svg viewBox="0 0 1 1"><path fill="red" d="M0 0h1v1z"/></svg>`},
+	}
+
+	for _, test := range tests {
+		passes, err := SelectPasses(test.passes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc, err := ParseString(test.in)
+		if err != nil {
+			t.Fatalf("%s: %v", test.passes, err)
+		}
+		Run(doc, passes, &Options{Precision: 1, Fonts: []*sfnt.Font{font}})
+		if got := string(doc.Bytes()); got != test.want {
+			t.Errorf("%s: %s\ngot  %s\nwant %s", test.passes, test.in, got, test.want)
+		}
+	}
+}
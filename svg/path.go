@@ -0,0 +1,458 @@
+package svg
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Segment is a single path command with absolute coordinates.
+//
+// Command is one of M, L, H, V, C, S, Q, T, A or Z.
+type Segment struct {
+	Command byte
+	Args    []float64
+}
+
+// Path is parsed path data.
+type Path []Segment
+
+// argCount is the number of arguments for each command.
+var argCount = map[byte]int{
+	'M': 2, 'L': 2, 'H': 1, 'V': 1,
+	'C': 6, 'S': 4, 'Q': 4, 'T': 2,
+	'A': 7, 'Z': 0,
+}
+
+// ParsePath parses path data and converts all commands to absolute coordinates.
+func ParsePath(d string) (Path, error) {
+	p := &pathScanner{s: d}
+
+	var path Path
+	var cur, start [2]float64
+	var command byte
+	for {
+		p.skip()
+		if p.eof() {
+			break
+		}
+
+		c := p.s[p.i]
+		if isPathCommand(c) {
+			command = c
+			p.i++
+		} else if command == 0 {
+			return nil, fmt.Errorf("path must start with a command at %d", p.i)
+		}
+
+		upper := toUpper(command)
+		relative := command != upper
+		args := make([]float64, argCount[upper])
+		for k := range args {
+			var err error
+			if upper == 'A' && (k == 3 || k == 4) {
+				args[k], err = p.flag()
+			} else {
+				args[k], err = p.number()
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if relative {
+			switch upper {
+			case 'H':
+				args[0] += cur[0]
+			case 'V':
+				args[0] += cur[1]
+			case 'A':
+				args[5] += cur[0]
+				args[6] += cur[1]
+			default:
+				for k := 0; k+1 < len(args); k += 2 {
+					args[k] += cur[0]
+					args[k+1] += cur[1]
+				}
+			}
+		}
+		path = append(path, Segment{Command: upper, Args: args})
+
+		switch upper {
+		case 'Z':
+			cur = start
+		case 'H':
+			cur[0] = args[0]
+		case 'V':
+			cur[1] = args[0]
+		default:
+			cur = [2]float64{args[len(args)-2], args[len(args)-1]}
+		}
+
+		switch command {
+		case 'M':
+			start = cur
+			command = 'L'
+		case 'm':
+			start = cur
+			command = 'l'
+		case 'Z', 'z':
+			// z can't be repeated implicitly
+			command = 0
+		}
+	}
+	return path, nil
+}
+
+func isPathCommand(c byte) bool {
+	_, ok := argCount[toUpper(c)]
+	return ok
+}
+
+func toUpper(c byte) byte {
+	if 'a' <= c && c <= 'z' {
+		return c - 'a' + 'A'
+	}
+	return c
+}
+
+// pathScanner reads numbers from path data.
+type pathScanner struct {
+	s string
+	i int
+}
+
+func (p *pathScanner) eof() bool { return p.i >= len(p.s) }
+
+// skip skips whitespace and a single comma.
+func (p *pathScanner) skip() {
+	for !p.eof() && isSpace(p.s[p.i]) {
+		p.i++
+	}
+	if !p.eof() && p.s[p.i] == ',' {
+		p.i++
+		for !p.eof() && isSpace(p.s[p.i]) {
+			p.i++
+		}
+	}
+}
+
+func (p *pathScanner) number() (float64, error) {
+	p.skip()
+	start := p.i
+	if !p.eof() && (p.s[p.i] == '+' || p.s[p.i] == '-') {
+		p.i++
+	}
+	digits := 0
+	for !p.eof() && '0' <= p.s[p.i] && p.s[p.i] <= '9' {
+		p.i++
+		digits++
+	}
+	if !p.eof() && p.s[p.i] == '.' {
+		p.i++
+		for !p.eof() && '0' <= p.s[p.i] && p.s[p.i] <= '9' {
+			p.i++
+			digits++
+		}
+	}
+	if digits == 0 {
+		return 0, fmt.Errorf("expected number at %d", start)
+	}
+	if !p.eof() && (p.s[p.i] == 'e' || p.s[p.i] == 'E') {
+		k := p.i + 1
+		if k < len(p.s) && (p.s[k] == '+' || p.s[k] == '-') {
+			k++
+		}
+		if k < len(p.s) && '0' <= p.s[k] && p.s[k] <= '9' {
+			for k < len(p.s) && '0' <= p.s[k] && p.s[k] <= '9' {
+				k++
+			}
+			p.i = k
+		}
+	}
+	return strconv.ParseFloat(p.s[start:p.i], 64)
+}
+
+// flag reads an arc flag, which may be written without separators.
+func (p *pathScanner) flag() (float64, error) {
+	p.skip()
+	if !p.eof() {
+		switch p.s[p.i] {
+		case '0':
+			p.i++
+			return 0, nil
+		case '1':
+			p.i++
+			return 1, nil
+		}
+	}
+	return 0, fmt.Errorf("expected flag at %d", p.i)
+}
+
+// Round rounds all coordinates to the number of decimals.
+func (path Path) Round(decimals int) {
+	for _, seg := range path {
+		for k, v := range seg.Args {
+			if seg.Command == 'A' && (k == 3 || k == 4) {
+				continue
+			}
+			seg.Args[k] = round(v, decimals)
+		}
+	}
+}
+
+// Translate moves the path by dx, dy.
+func (path Path) Translate(dx, dy float64) {
+	for _, seg := range path {
+		switch seg.Command {
+		case 'H':
+			seg.Args[0] += dx
+		case 'V':
+			seg.Args[0] += dy
+		case 'A':
+			seg.Args[5] += dx
+			seg.Args[6] += dy
+		default:
+			for k := 0; k+1 < len(seg.Args); k += 2 {
+				seg.Args[k] += dx
+				seg.Args[k+1] += dy
+			}
+		}
+	}
+}
+
+// controlBox returns a box that contains the path, including all control points.
+func (path Path) controlBox() Box {
+	box := EmptyBox()
+	var cur [2]float64
+	for _, seg := range path {
+		switch seg.Command {
+		case 'Z':
+			continue
+		case 'H':
+			cur[0] = seg.Args[0]
+		case 'V':
+			cur[1] = seg.Args[0]
+		case 'A':
+			// the arc is at most a diameter away from its end points
+			end := [2]float64{seg.Args[5], seg.Args[6]}
+			r := 2 * math.Max(math.Abs(seg.Args[0]), math.Abs(seg.Args[1]))
+			r = math.Max(r, math.Hypot(end[0]-cur[0], end[1]-cur[1]))
+			box = box.Add(cur[0]-r, cur[1]-r).Add(cur[0]+r, cur[1]+r)
+			cur = end
+		default:
+			for k := 0; k+1 < len(seg.Args); k += 2 {
+				box = box.Add(seg.Args[k], seg.Args[k+1])
+			}
+			cur = [2]float64{seg.Args[len(seg.Args)-2], seg.Args[len(seg.Args)-1]}
+		}
+		box = box.Add(cur[0], cur[1])
+	}
+	return box
+}
+
+// String formats the path without losing precision.
+func (path Path) String() string { return path.Format(-1) }
+
+// Format formats the path in the shortest form, choosing between
+// absolute and relative commands for every segment.
+//
+// Coordinates are rounded to the number of decimals,
+// negative decimals keep the full precision.
+func (path Path) Format(decimals int) string {
+	var b strings.Builder
+
+	var cur, start [2]float64
+	var last byte // last command written
+	var lastNumber string
+	for _, seg := range path {
+		var candidates [][]string
+		var letters []byte
+
+		abs := make([]string, len(seg.Args))
+		rel := make([]string, len(seg.Args))
+		for k, v := range seg.Args {
+			abs[k] = formatNumber(v, decimals)
+			rel[k] = abs[k]
+		}
+
+		switch seg.Command {
+		case 'Z':
+			letters = append(letters, 'z')
+			candidates = append(candidates, nil)
+		case 'H':
+			rel[0] = formatNumber(seg.Args[0]-cur[0], decimals)
+		case 'V':
+			rel[0] = formatNumber(seg.Args[0]-cur[1], decimals)
+		case 'A':
+			rel[5] = formatNumber(seg.Args[5]-cur[0], decimals)
+			rel[6] = formatNumber(seg.Args[6]-cur[1], decimals)
+		default:
+			for k := 0; k+1 < len(seg.Args); k += 2 {
+				rel[k] = formatNumber(seg.Args[k]-cur[0], decimals)
+				rel[k+1] = formatNumber(seg.Args[k+1]-cur[1], decimals)
+			}
+		}
+
+		if seg.Command != 'Z' {
+			letters = append(letters, seg.Command, seg.Command-'A'+'a')
+			candidates = append(candidates, abs, rel)
+
+			// horizontal and vertical lines
+			if seg.Command == 'L' {
+				if rel[1] == "0" {
+					letters = append(letters, 'H', 'h')
+					candidates = append(candidates, abs[:1], rel[:1])
+				} else if rel[0] == "0" {
+					letters = append(letters, 'V', 'v')
+					candidates = append(candidates, abs[1:], rel[1:])
+				}
+			}
+		}
+
+		best, bestLast, bestIndex := "", "", 0
+		for i, args := range candidates {
+			s, lastArg := formatSegment(letters[i], args, last, lastNumber)
+			if i == 0 || len(s) < len(best) {
+				best, bestLast, bestIndex = s, lastArg, i
+			}
+		}
+		b.WriteString(best)
+		last, lastNumber = letters[bestIndex], bestLast
+
+		// track the point as it will be read, so that rounding errors don't accumulate
+		args, letter := candidates[bestIndex], letters[bestIndex]
+		var origin [2]float64
+		if letter != toUpper(letter) {
+			origin = cur
+		}
+		switch toUpper(letter) {
+		case 'Z':
+			cur = start
+		case 'H':
+			cur[0] = origin[0] + parseNumber(args[0])
+		case 'V':
+			cur[1] = origin[1] + parseNumber(args[0])
+		default:
+			cur = [2]float64{
+				origin[0] + parseNumber(args[len(args)-2]),
+				origin[1] + parseNumber(args[len(args)-1]),
+			}
+		}
+		if seg.Command == 'M' {
+			start = cur
+		}
+	}
+	return b.String()
+}
+
+// formatSegment formats a single segment, omitting the command letter
+// when it's implied by the previous command.
+func formatSegment(letter byte, args []string, last byte, lastNumber string) (string, string) {
+	var b strings.Builder
+
+	implied := last
+	switch last {
+	case 'M':
+		implied = 'L'
+	case 'm':
+		implied = 'l'
+	case 'z', 'Z':
+		implied = 0
+	}
+	if letter != implied || letter == 'z' {
+		b.WriteByte(letter)
+		lastNumber = ""
+	}
+
+	for _, arg := range args {
+		if lastNumber != "" && needsSeparator(lastNumber, arg) {
+			b.WriteByte(' ')
+		}
+		b.WriteString(arg)
+		lastNumber = arg
+	}
+	return b.String(), lastNumber
+}
+
+// needsSeparator reports whether next can't be written directly after prev.
+func needsSeparator(prev, next string) bool {
+	switch {
+	case strings.HasPrefix(next, "-"):
+		return false
+	case strings.HasPrefix(next, "."):
+		return !strings.Contains(prev, ".") || strings.ContainsAny(prev, "eE")
+	}
+	return true
+}
+
+// joinNumbers joins formatted numbers with the fewest separators.
+func joinNumbers(xs ...string) string {
+	var b strings.Builder
+	for i, x := range xs {
+		if i > 0 && needsSeparator(xs[i-1], x) {
+			b.WriteByte(' ')
+		}
+		b.WriteString(x)
+	}
+	return b.String()
+}
+
+// formatNumber formats v in the shortest form with at most decimals
+// fractional digits, negative decimals keep the full precision.
+func formatNumber(v float64, decimals int) string {
+	s := strconv.FormatFloat(round(v, decimals), 'f', decimals, 64)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(s, "0")
+		s = strings.TrimSuffix(s, ".")
+	}
+	switch {
+	case s == "-0":
+		s = "0"
+	case strings.HasPrefix(s, "0."):
+		s = s[1:]
+	case strings.HasPrefix(s, "-0."):
+		s = "-" + s[2:]
+	}
+	return s
+}
+
+// parseNumber parses a number formatted by formatNumber.
+func parseNumber(s string) float64 {
+	v, _ := strconv.ParseFloat(s, 64)
+	return v
+}
+
+// round rounds v to the number of decimals, negative decimals leave v as is.
+func round(v float64, decimals int) float64 {
+	if decimals < 0 {
+		return v
+	}
+	scale := math.Pow(10, float64(decimals))
+	return math.Round(v*scale) / scale
+}
+
+// maxDecimals returns the largest number of fractional digits
+// used by the numbers in s.
+func maxDecimals(s string) int {
+	if strings.ContainsAny(s, "eE") {
+		// numbers with exponents are rare, keep them as is
+		return -1
+	}
+	max := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] != '.' {
+			continue
+		}
+		n := 0
+		for i+1 < len(s) && '0' <= s[i+1] && s[i+1] <= '9' {
+			i++
+			n++
+		}
+		if n > max {
+			max = n
+		}
+	}
+	return max
+}
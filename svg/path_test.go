@@ -0,0 +1,85 @@
+package svg
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPathRoundTrip(t *testing.T) {
+	tests := []string{
+		"M0 0L10 10H20V30Z",
+		"m10 10 5 5h-2v-3z",
+		"M1.5-2.5C1 2 3 4 5 6S7 8 9 10",
+		"M0 0Q5 10 10 0T20 0",
+		"M10 10A5 5 0 1 0 20 20a5 5 30 0 1 10 10",
+		"M0,0 l.5.5 .5-.5e1",
+		"M0 0zm5 5l1 1z",
+	}
+	for _, d := range tests {
+		path, err := ParsePath(d)
+		if err != nil {
+			t.Errorf("%q: %v", d, err)
+			continue
+		}
+		formatted := path.String()
+		again, err := ParsePath(formatted)
+		if err != nil {
+			t.Errorf("%q: formatted as %q: %v", d, formatted, err)
+			continue
+		}
+		if !samePath(path, again) {
+			t.Errorf("%q: formatted as %q, parsed as %v, expected %v", d, formatted, again, path)
+		}
+	}
+}
+
+func TestPathFormat(t *testing.T) {
+	tests := []struct {
+		d        string
+		decimals int
+		want     string
+	}{
+		{"M 10,10 L 20,10 L 20,20 Z", -1, "M10 10H20V20z"},
+		{"M 100,100 l 1,1 l 1,1", -1, "M100 100l1 1 1 1"},
+		// relative coordinates are relative to the rounded position
+		{"M 0.25,0.75 L 1.125,1.5", 1, "M.3.8l.8.7"},
+		{"M -1,-1 C -1,-2 -3,-4 -5,-6", -1, "M-1-1c0-1-2-3-4-5"},
+	}
+	for _, test := range tests {
+		path, err := ParsePath(test.d)
+		if err != nil {
+			t.Errorf("%q: %v", test.d, err)
+			continue
+		}
+		if got := path.Format(test.decimals); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.d, got, test.want)
+		}
+	}
+}
+
+func TestParsePathErrors(t *testing.T) {
+	for _, d := range []string{"M 1", "M 0 0 X 1 1", "M 0 0 A 1 1 0 2 0 1 1"} {
+		if path, err := ParsePath(d); err == nil {
+			t.Errorf("%q: expected an error, got %v", d, path)
+		}
+	}
+}
+
+// samePath reports whether a and b have the same commands and
+// arguments, up to floating point errors.
+func samePath(a, b Path) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Command != b[i].Command || len(a[i].Args) != len(b[i].Args) {
+			return false
+		}
+		for k := range a[i].Args {
+			if math.Abs(a[i].Args[k]-b[i].Args[k]) > 1e-9 {
+				return false
+			}
+		}
+	}
+	return true
+}
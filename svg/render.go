@@ -0,0 +1,168 @@
+package svg
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Render writes the document to w.
+func (doc *Document) Render(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, node := range doc.Nodes {
+		node.render(bw)
+	}
+	return bw.Flush()
+}
+
+// Bytes returns the rendered document.
+func (doc *Document) Bytes() []byte {
+	var buf bytes.Buffer
+	doc.Render(&buf)
+	return buf.Bytes()
+}
+
+// Render writes node and its descendants to w.
+func (node *Node) Render(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	node.render(bw)
+	return bw.Flush()
+}
+
+func (node *Node) render(w *bufio.Writer) {
+	switch node.Kind {
+	case Text:
+		w.WriteString(node.Raw)
+	case Comment:
+		w.WriteString("<!--")
+		w.WriteString(node.Raw)
+		w.WriteString("-->")
+	case ProcInst:
+		w.WriteString("<?")
+		w.WriteString(node.Raw)
+		w.WriteString("?>")
+	case Directive:
+		w.WriteString("<!")
+		w.WriteString(node.Raw)
+		w.WriteString(">")
+	case CData:
+		w.WriteString("<![CDATA[")
+		w.WriteString(node.Raw)
+		w.WriteString("]]>")
+	case Element:
+		w.WriteByte('<')
+		w.WriteString(node.Name)
+		for _, attr := range node.Attrs {
+			attr.render(w)
+		}
+		w.WriteString(node.TagSpace)
+		if node.SelfClosing && len(node.Children) == 0 {
+			w.WriteString("/>")
+			return
+		}
+		w.WriteByte('>')
+		for _, child := range node.Children {
+			child.render(w)
+		}
+		w.WriteString("</")
+		w.WriteString(node.Name)
+		w.WriteString(node.EndSpace)
+		w.WriteByte('>')
+	}
+}
+
+func (attr *Attr) render(w *bufio.Writer) {
+	space := attr.Space
+	if space == "" {
+		space = " "
+	}
+	eq := attr.Eq
+	if eq == "" {
+		eq = "="
+	}
+	quote := attr.Quote
+	if quote == 0 {
+		quote = '"'
+	}
+
+	w.WriteString(space)
+	w.WriteString(attr.Name)
+	w.WriteString(eq)
+	w.WriteByte(quote)
+	if attr.Value == attr.rawValue && attr.raw != "" {
+		w.WriteString(attr.raw)
+	} else {
+		w.WriteString(escapeAttr(attr.Value, quote))
+	}
+	w.WriteByte(quote)
+}
+
+var textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// EscapeText escapes s for use as character data.
+func EscapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+func escapeAttr(s string, quote byte) string {
+	s = strings.NewReplacer("&", "&amp;", "<", "&lt;", "\n", "&#10;", "\t", "&#9;").Replace(s)
+	if quote == '"' {
+		return strings.Replace(s, `"`, "&quot;", -1)
+	}
+	return strings.Replace(s, "'", "&apos;", -1)
+}
+
+// UnescapeText resolves predefined entities and character references.
+func UnescapeText(s string) string {
+	if !strings.Contains(s, "&") {
+		return s
+	}
+
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(s, '&')
+		if i < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+		b.WriteString(s[:i])
+		s = s[i:]
+
+		end := strings.IndexByte(s, ';')
+		if end < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+
+		entity := s[1:end]
+		switch {
+		case entity == "amp":
+			b.WriteByte('&')
+		case entity == "lt":
+			b.WriteByte('<')
+		case entity == "gt":
+			b.WriteByte('>')
+		case entity == "quot":
+			b.WriteByte('"')
+		case entity == "apos":
+			b.WriteByte('\'')
+		case strings.HasPrefix(entity, "#x"):
+			if v, err := strconv.ParseUint(entity[2:], 16, 32); err == nil {
+				b.WriteRune(rune(v))
+			} else {
+				b.WriteString(s[:end+1])
+			}
+		case strings.HasPrefix(entity, "#"):
+			if v, err := strconv.ParseUint(entity[1:], 10, 32); err == nil {
+				b.WriteRune(rune(v))
+			} else {
+				b.WriteString(s[:end+1])
+			}
+		default:
+			b.WriteString(s[:end+1])
+		}
+		s = s[end+1:]
+	}
+}
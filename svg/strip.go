@@ -0,0 +1,156 @@
+package svg
+
+import (
+	"strings"
+)
+
+// editorNamespaces are namespaces only used by editors.
+var editorNamespaces = map[string]bool{
+	"http://www.inkscape.org/namespaces/inkscape":            true,
+	"http://sodipodi.sourceforge.net/DTD/sodipodi-0.dtd":     true,
+	"http://www.serif.com/":                                  true,
+	"http://www.openswatchbook.org/uri/2009/osb":             true,
+	"http://www.w3.org/1999/02/22-rdf-syntax-ns#":            true,
+	"http://creativecommons.org/ns#":                         true,
+	"http://purl.org/dc/elements/1.1/":                       true,
+	"http://ns.adobe.com/AdobeIllustrator/10.0/":             true,
+	"http://ns.adobe.com/SaveForWeb/1.0/":                    true,
+	"http://www.bohemiancoding.com/sketch/ns":                true,
+	"http://inkscape.sourceforge.net/DTD/sodipodi-0.dtd":     true,
+	"http://www.inkscape.org/namespaces/inkscape/extensions": true,
+}
+
+// Namespaces returns the prefixes declared anywhere in the document
+// with their namespace URI.
+func (doc *Document) Namespaces() map[string]string {
+	namespaces := map[string]string{}
+	doc.Walk(func(node *Node) bool {
+		for _, attr := range node.Attrs {
+			if Prefix(attr.Name) == "xmlns" {
+				namespaces[Local(attr.Name)] = attr.Value
+			}
+		}
+		return true
+	})
+	return namespaces
+}
+
+// IsLayer reports whether node is an Inkscape layer.
+func (node *Node) IsLayer() bool {
+	return node.Is("g") && node.Get("inkscape:groupmode") == "layer"
+}
+
+// StripEditorDataPass removes data that only editors use.
+var StripEditorDataPass = &Pass{
+	Name:        "strip-editor-data",
+	Description: "remove editor metadata, namespaces and empty groups, see -keep-layer-names",
+	Run: func(doc *Document, opts *Options, report *Report) {
+		namespaces := doc.Namespaces()
+		isEditor := func(name string) bool {
+			prefix := Prefix(name)
+			return prefix != "" && prefix != "xmlns" && editorNamespaces[namespaces[prefix]]
+		}
+
+		// editor elements and metadata
+		doc.Walk(func(node *Node) bool {
+			if node.Kind != Element {
+				return true
+			}
+			if isEditor(node.Name) || node.Is("metadata") {
+				node.Detach()
+				report.Changef("removed %s", describe(node))
+				return false
+			}
+			return true
+		})
+
+		// swatches, gradients marked with osb:paint, lose their marker below
+		var swatches []*Node
+		doc.Walk(func(node *Node) bool {
+			if node.Kind != Element || !node.IsGradient() {
+				return true
+			}
+			for _, attr := range node.Attrs {
+				if Local(attr.Name) == "paint" && namespaces[Prefix(attr.Name)] == "http://www.openswatchbook.org/uri/2009/osb" {
+					swatches = append(swatches, node)
+					break
+				}
+			}
+			return true
+		})
+
+		// editor attributes
+		removed := map[string]bool{}
+		doc.Walk(func(node *Node) bool {
+			if node.Kind != Element {
+				return true
+			}
+			keepLayer := opts.KeepLayerNames && node.IsLayer()
+			for _, attr := range append([]*Attr{}, node.Attrs...) {
+				if !isEditor(attr.Name) {
+					continue
+				}
+				if keepLayer && (attr.Name == "inkscape:label" || attr.Name == "inkscape:groupmode") {
+					continue
+				}
+				node.Remove(attr.Name)
+				removed[attr.Name] = true
+			}
+			return true
+		})
+		for _, name := range sortedKeys(removed) {
+			report.Changef("removed attribute %s", name)
+		}
+
+		// swatches that nothing uses
+		refs := doc.References()
+		for _, swatch := range swatches {
+			if !refs[swatch.ID()] {
+				swatch.Detach()
+				report.Changef("removed unused swatch %s", describe(swatch))
+			}
+		}
+
+		// empty groups, children first so that nested empty groups vanish
+		var removeEmpty func(node *Node)
+		removeEmpty = func(node *Node) {
+			for _, child := range node.Elements() {
+				removeEmpty(child)
+			}
+			if !node.Is("g") || refs[node.ID()] && node.ID() != "" {
+				return
+			}
+			if len(node.Elements()) == 0 && strings.TrimSpace(node.Text()) == "" {
+				node.Detach()
+				report.Changef("removed empty %s", describe(node))
+			}
+		}
+		if root := doc.Root(); root != nil {
+			removeEmpty(root)
+		}
+
+		// namespace declarations that are not used anymore
+		used := map[string]bool{}
+		doc.Walk(func(node *Node) bool {
+			if node.Kind != Element {
+				return true
+			}
+			used[Prefix(node.Name)] = true
+			for _, attr := range node.Attrs {
+				if Prefix(attr.Name) != "xmlns" {
+					used[Prefix(attr.Name)] = true
+				}
+			}
+			return true
+		})
+		doc.Walk(func(node *Node) bool {
+			for _, attr := range append([]*Attr{}, node.Attrs...) {
+				if Prefix(attr.Name) == "xmlns" && !used[Local(attr.Name)] {
+					node.Remove(attr.Name)
+					report.Changef("removed unused namespace %s", attr.Name)
+				}
+			}
+			return true
+		})
+	},
+}
@@ -0,0 +1,32 @@
+package svg
+
+import "testing"
+
+func TestStripKeepLayerNames(t *testing.T) {
+	doc, err := ParseString(`<svg xmlns:inkscape="http://www.inkscape.org/namespaces/inkscape" xmlns:x="http://example.com/x"><g inkscape:label="Head" inkscape:groupmode="layer" inkscape:highlight-color="red" x:keep="1"><g inkscape:label="Eye"><path/></g></g><g id="ref"/><use href="#ref"/></svg>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if layers := doc.Root().Elements()[0]; !layers.IsLayer() || layers.Elements()[0].IsLayer() {
+		t.Fatalf("expected only the outer group to be a layer")
+	}
+
+	Run(doc, []*Pass{StripEditorDataPass}, &Options{KeepLayerNames: true})
+	want := `<svg xmlns:inkscape="http://www.inkscape.org/namespaces/inkscape" xmlns:x="http://example.com/x"><g inkscape:label="Head" inkscape:groupmode="layer" x:keep="1"><g><path/></g></g><g id="ref"/><use href="#ref"/></svg>`
+	if got := string(doc.Bytes()); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestStripUnusedSwatches(t *testing.T) {
+	doc, err := ParseString(`<svg xmlns:osb="http://www.openswatchbook.org/uri/2009/osb"><defs><linearGradient id="unused" osb:paint="solid"><stop/></linearGradient><linearGradient id="used" osb:paint="solid"><stop/></linearGradient><linearGradient id="plain"><stop/></linearGradient></defs><path fill="url(#used)"/></svg>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Run(doc, []*Pass{StripEditorDataPass}, &Options{})
+	want := `<svg><defs><linearGradient id="used"><stop/></linearGradient><linearGradient id="plain"><stop/></linearGradient></defs><path fill="url(#used)"/></svg>`
+	if got := string(doc.Bytes()); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
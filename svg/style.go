@@ -0,0 +1,135 @@
+package svg
+
+import (
+	"strings"
+)
+
+// Declaration is a single property in a style attribute.
+type Declaration struct {
+	Name  string
+	Value string
+}
+
+// Style is the parsed content of a style attribute, in the original order.
+type Style []Declaration
+
+// ParseStyle parses declarations of the form "name:value;name:value".
+func ParseStyle(s string) Style {
+	var style Style
+	for _, part := range strings.Split(s, ";") {
+		i := strings.IndexByte(part, ':')
+		if i < 0 {
+			continue
+		}
+		name := strings.TrimSpace(part[:i])
+		if name == "" {
+			continue
+		}
+		style = append(style, Declaration{
+			Name:  name,
+			Value: strings.TrimSpace(part[i+1:]),
+		})
+	}
+	return style
+}
+
+// Get returns the value of the property name.
+func (style Style) Get(name string) (string, bool) {
+	for _, decl := range style {
+		if decl.Name == name {
+			return decl.Value, true
+		}
+	}
+	return "", false
+}
+
+// Set sets the property name, appending it when missing.
+func (style *Style) Set(name, value string) {
+	for i := range *style {
+		if (*style)[i].Name == name {
+			(*style)[i].Value = value
+			return
+		}
+	}
+	*style = append(*style, Declaration{name, value})
+}
+
+// Remove removes the property name and reports whether it existed.
+func (style *Style) Remove(name string) bool {
+	for i, decl := range *style {
+		if decl.Name == name {
+			*style = append((*style)[:i], (*style)[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (style Style) String() string {
+	var b strings.Builder
+	for i, decl := range style {
+		if i > 0 {
+			b.WriteByte(';')
+		}
+		b.WriteString(decl.Name)
+		b.WriteByte(':')
+		b.WriteString(decl.Value)
+	}
+	return b.String()
+}
+
+// Style returns the parsed style attribute of node.
+func (node *Node) Style() Style {
+	return ParseStyle(node.Get("style"))
+}
+
+// SetStyle replaces the style attribute, removing it when style is empty.
+func (node *Node) SetStyle(style Style) {
+	if len(style) == 0 {
+		node.Remove("style")
+		return
+	}
+	node.Set("style", style.String())
+}
+
+// Property returns the value of a presentation property either
+// from the style attribute or the attribute with the same name.
+func (node *Node) Property(name string) (string, bool) {
+	if value, ok := node.Style().Get(name); ok {
+		return value, true
+	}
+	if attr := node.Attr(name); attr != nil {
+		return attr.Value, true
+	}
+	return "", false
+}
+
+// SetProperty sets a presentation property where it's currently
+// specified, either in the style attribute or as an attribute.
+// Missing properties are added to the style attribute.
+func (node *Node) SetProperty(name, value string) {
+	style := node.Style()
+	if _, ok := style.Get(name); !ok && node.Has(name) {
+		node.Set(name, value)
+		return
+	}
+	style.Set(name, value)
+	node.SetStyle(style)
+}
+
+// URLRef returns the id referenced by a value of the form "url(#id)".
+func URLRef(value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, "url(") {
+		return "", false
+	}
+	end := strings.IndexByte(value, ')')
+	if end < 0 {
+		return "", false
+	}
+	ref := strings.Trim(strings.TrimSpace(value[4:end]), `"'`)
+	if !strings.HasPrefix(ref, "#") {
+		return "", false
+	}
+	return ref[1:], true
+}
@@ -0,0 +1,467 @@
+package svg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// textDecimals is the precision of the outlines created from text.
+const textDecimals = 3
+
+// TextToPathPass replaces text elements by groups of paths using
+// Options.Fonts, so that the result doesn't depend on installed fonts.
+//
+// The font is selected by the family name, font weight, style and
+// rotate are not taken into account and are reported when ignored.
+// Text on a path is left as is.
+var TextToPathPass = &Pass{
+	Name:        "text-to-path",
+	Description: "convert text to paths, see -font",
+	Run: func(doc *Document, opts *Options, report *Report) {
+		if len(opts.Fonts) == 0 {
+			return
+		}
+		fonts := newFontSet(opts.Fonts)
+
+		doc.Walk(func(node *Node) bool {
+			if !node.Is("text") {
+				return true
+			}
+			if len(node.ElementsByName("textPath")) > 0 {
+				report.Changef("%s: skipped text on a path", describe(node))
+				return false
+			}
+
+			name := describe(node)
+			missing, notes := textToPath(node, fonts)
+			report.Changef("%s: converted to paths", name)
+			for _, note := range notes {
+				report.Changef("%s: %s", name, note)
+			}
+			if missing != "" {
+				report.Changef("%s: font has no glyphs for %q", name, missing)
+			}
+			return false
+		})
+	},
+}
+
+// fontSet finds fonts by family name.
+type fontSet struct {
+	buf      sfnt.Buffer
+	fallback *sfnt.Font
+	families map[string]*sfnt.Font
+	// fallbackName is the family name of fallback
+	fallbackName string
+}
+
+func newFontSet(fonts []*sfnt.Font) *fontSet {
+	set := &fontSet{
+		fallback: fonts[0],
+		families: map[string]*sfnt.Font{},
+	}
+	for _, f := range fonts {
+		if name, err := f.Name(&set.buf, sfnt.NameIDFamily); err == nil {
+			set.families[strings.ToLower(name)] = f
+			if f == set.fallback {
+				set.fallbackName = name
+			}
+		}
+	}
+	return set
+}
+
+// lookup returns the first font from a font-family list and
+// whether it was found, otherwise the fallback font.
+func (set *fontSet) lookup(families string) (*sfnt.Font, bool) {
+	for _, family := range strings.Split(families, ",") {
+		family = strings.ToLower(strings.Trim(strings.TrimSpace(family), `"'`))
+		if f, ok := set.families[family]; ok {
+			return f, true
+		}
+	}
+	return set.fallback, false
+}
+
+// glyph is a laid out character.
+type glyph struct {
+	owner *Node
+	font  *sfnt.Font
+	index sfnt.GlyphIndex
+	size  float64
+	x, y  float64
+	chunk int
+}
+
+// textChar is a character after whitespace processing.
+type textChar struct {
+	r     rune
+	owner *Node
+	// chain is the owner and its ancestors up to the text element
+	chain []*Node
+}
+
+// textToPath converts the text element node into a group of paths
+// and returns the characters missing from the fonts and notes about
+// the properties, which could not be converted.
+func textToPath(node *Node, fonts *fontSet) (string, []string) {
+	chars := textChars(node)
+
+	var notes []string
+	noted := map[string]bool{}
+	note := func(format string, args ...interface{}) {
+		text := fmt.Sprintf(format, args...)
+		if !noted[text] {
+			noted[text] = true
+			notes = append(notes, text)
+		}
+	}
+
+	// positions given by x, y, dx and dy, indexed by character
+	lists := map[*Node]map[string][]float64{}
+	for _, c := range chars {
+		for _, n := range c.chain {
+			if _, ok := lists[n]; ok {
+				continue
+			}
+			lists[n] = map[string][]float64{}
+			for _, name := range []string{"x", "y", "dx", "dy"} {
+				lists[n][name] = parseLengths(n.Get(name))
+			}
+		}
+	}
+	counts := map[*Node]int{}
+	position := func(c textChar, name string) (float64, bool) {
+		for _, n := range c.chain {
+			if values := lists[n][name]; counts[n] < len(values) {
+				return values[counts[n]], true
+			}
+		}
+		return 0, false
+	}
+
+	var missing []rune
+	var glyphs []glyph
+	var x, y float64
+	chunk := 0
+	for i, c := range chars {
+		family := inheritedValue(c.owner, "font-family", "")
+		f, found := fonts.lookup(family)
+		if !found && family != "" {
+			note("font-family %s not in -font, used %s", family, fonts.fallbackName)
+		}
+		if weight := inheritedValue(c.owner, "font-weight", "normal"); weight != "normal" && weight != "400" {
+			note("ignored font-weight %s", weight)
+		}
+		if style := inheritedValue(c.owner, "font-style", "normal"); style != "normal" {
+			note("ignored font-style %s", style)
+		}
+		for _, n := range c.chain {
+			if n.Get("rotate") != "" {
+				note("ignored rotate on %s", describe(n))
+			}
+		}
+		size := parseFontSize(c.owner)
+		scale := size / float64(f.UnitsPerEm())
+		ppem := fixed.I(int(f.UnitsPerEm()))
+
+		index, err := f.GlyphIndex(&fonts.buf, c.r)
+		if err != nil || index == 0 {
+			if !unicode.IsSpace(c.r) {
+				missing = append(missing, c.r)
+			}
+		}
+
+		if len(glyphs) > 0 && glyphs[len(glyphs)-1].font == f {
+			prev := glyphs[len(glyphs)-1]
+			if kern, err := f.Kern(&fonts.buf, prev.index, index, ppem, font.HintingNone); err == nil {
+				x += float64(kern) / 64 * scale
+			}
+		}
+
+		// an absolute x or y starts a new text chunk
+		absolute := false
+		if v, ok := position(c, "x"); ok {
+			x = v
+			absolute = true
+		}
+		if v, ok := position(c, "y"); ok {
+			y = v
+			absolute = true
+		}
+		if absolute && i > 0 {
+			chunk++
+		}
+		if v, ok := position(c, "dx"); ok {
+			x += v
+		}
+		if v, ok := position(c, "dy"); ok {
+			y += v
+		}
+		for _, n := range c.chain {
+			counts[n]++
+		}
+
+		glyphs = append(glyphs, glyph{
+			owner: c.owner,
+			font:  f,
+			index: index,
+			size:  size,
+			x:     x,
+			y:     y,
+			chunk: chunk,
+		})
+
+		if advance, err := f.GlyphAdvance(&fonts.buf, index, ppem, font.HintingNone); err == nil {
+			x += float64(advance) / 64 * scale
+		}
+		x += parseLength(inheritedValue(c.owner, "letter-spacing", "0"))
+		if c.r == ' ' {
+			x += parseLength(inheritedValue(c.owner, "word-spacing", "0"))
+		}
+	}
+
+	// text-anchor moves whole chunks
+	for start := 0; start < len(glyphs); {
+		end := start
+		for end < len(glyphs) && glyphs[end].chunk == glyphs[start].chunk {
+			end++
+		}
+		last := glyphs[end-1]
+		advance, _ := last.font.GlyphAdvance(&fonts.buf, last.index, fixed.I(int(last.font.UnitsPerEm())), font.HintingNone)
+		width := last.x + float64(advance)/64*last.size/float64(last.font.UnitsPerEm()) - glyphs[start].x
+
+		shift := 0.0
+		switch inheritedValue(glyphs[start].owner, "text-anchor", "start") {
+		case "middle":
+			shift = -width / 2
+		case "end":
+			shift = -width
+		}
+		for i := start; i < end; i++ {
+			glyphs[i].x += shift
+		}
+		start = end
+	}
+
+	// a path for every element containing text
+	var owners []*Node
+	paths := map[*Node]Path{}
+	for _, g := range glyphs {
+		if _, ok := paths[g.owner]; !ok {
+			owners = append(owners, g.owner)
+			paths[g.owner] = nil
+		}
+		paths[g.owner] = append(paths[g.owner], glyphOutline(&fonts.buf, g)...)
+	}
+
+	prefix := strings.TrimSuffix(node.Name, "text")
+	var children []*Node
+	for _, owner := range owners {
+		if len(paths[owner]) == 0 {
+			continue
+		}
+		path := NewElement(prefix + "path")
+		if owner != node {
+			for _, attr := range owner.Attrs {
+				if !isTextAttr(attr.Name) {
+					path.Set(attr.Name, attr.Value)
+				}
+			}
+		}
+		path.Set("d", paths[owner].Format(textDecimals))
+		children = append(children, path)
+	}
+
+	// reuse the element, so that the surrounding whitespace is kept
+	node.Name = prefix + "g"
+	for _, attr := range append([]*Attr{}, node.Attrs...) {
+		if isTextAttr(attr.Name) {
+			node.Remove(attr.Name)
+		}
+	}
+	node.Children = nil
+	node.SelfClosing = false
+	for _, child := range children {
+		node.AppendChild(child)
+	}
+
+	return string(missing), notes
+}
+
+// isTextAttr reports whether the attribute only applies to text elements.
+func isTextAttr(name string) bool {
+	switch name {
+	case "x", "y", "dx", "dy", "rotate", "textLength", "lengthAdjust", "xml:space":
+		return true
+	}
+	return Prefix(name) == "sodipodi"
+}
+
+// textChars returns the characters of a text element after
+// processing whitespace according to xml:space.
+func textChars(text *Node) []textChar {
+	var chars []textChar
+	var collect func(node *Node, chain []*Node, preserve bool)
+	collect = func(node *Node, chain []*Node, preserve bool) {
+		switch node.Kind {
+		case Text, CData:
+			for _, r := range node.Text() {
+				switch r {
+				case '\n', '\r':
+					if !preserve {
+						continue
+					}
+					r = ' '
+				case '\t':
+					r = ' '
+				}
+				chars = append(chars, textChar{r: r, owner: chain[0], chain: chain})
+			}
+		case Element:
+			if node != text && !node.Is("tspan") && !node.Is("a") {
+				return
+			}
+			if display, ok := node.Property("display"); ok && display == "none" {
+				return
+			}
+			if space := node.Get("xml:space"); space != "" {
+				preserve = space == "preserve"
+			}
+			chain = append([]*Node{node}, chain...)
+			for _, child := range node.Children {
+				collect(child, chain, preserve)
+			}
+		}
+	}
+	collect(text, nil, false)
+
+	// without xml:space="preserve" leading, trailing and repeated spaces are removed
+	if text.Get("xml:space") != "preserve" {
+		var collapsed []textChar
+		for _, c := range chars {
+			if c.r == ' ' && (len(collapsed) == 0 || collapsed[len(collapsed)-1].r == ' ') {
+				continue
+			}
+			collapsed = append(collapsed, c)
+		}
+		for len(collapsed) > 0 && collapsed[len(collapsed)-1].r == ' ' {
+			collapsed = collapsed[:len(collapsed)-1]
+		}
+		chars = collapsed
+	}
+	return chars
+}
+
+// glyphOutline returns the outline of a positioned glyph.
+func glyphOutline(buf *sfnt.Buffer, g glyph) Path {
+	upem := g.font.UnitsPerEm()
+	segments, err := g.font.LoadGlyph(buf, g.index, fixed.I(int(upem)), nil)
+	if err != nil {
+		return nil
+	}
+
+	scale := g.size / float64(upem) / 64
+	point := func(p fixed.Point26_6) (float64, float64) {
+		return g.x + float64(p.X)*scale, g.y + float64(p.Y)*scale
+	}
+
+	var path Path
+	for _, seg := range segments {
+		n := 1
+		switch seg.Op {
+		case sfnt.SegmentOpQuadTo:
+			n = 2
+		case sfnt.SegmentOpCubeTo:
+			n = 3
+		}
+		var args []float64
+		for _, p := range seg.Args[:n] {
+			px, py := point(p)
+			args = append(args, px, py)
+		}
+		switch seg.Op {
+		case sfnt.SegmentOpMoveTo:
+			if len(path) > 0 {
+				path = append(path, Segment{Command: 'Z'})
+			}
+			path = append(path, Segment{Command: 'M', Args: args})
+		case sfnt.SegmentOpLineTo:
+			path = append(path, Segment{Command: 'L', Args: args})
+		case sfnt.SegmentOpQuadTo:
+			path = append(path, Segment{Command: 'Q', Args: args})
+		case sfnt.SegmentOpCubeTo:
+			path = append(path, Segment{Command: 'C', Args: args})
+		}
+	}
+	if len(path) > 0 {
+		path = append(path, Segment{Command: 'Z'})
+	}
+	return path
+}
+
+// inheritedValue returns an inherited property or the default value.
+func inheritedValue(node *Node, name, def string) string {
+	if value, ok := node.Inherited(name); ok {
+		return value
+	}
+	return def
+}
+
+// parseFontSize returns the font size of node in user units.
+func parseFontSize(node *Node) float64 {
+	// relative sizes are resolved against the parent of the declaring element
+	for n := node; n != nil; n = n.Parent {
+		value, ok := n.Property("font-size")
+		if !ok || value == "inherit" {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case strings.HasSuffix(value, "%") && n.Parent != nil:
+			v, _ := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+			return v / 100 * parseFontSize(n.Parent)
+		case strings.HasSuffix(value, "em") && n.Parent != nil:
+			v, _ := strconv.ParseFloat(strings.TrimSuffix(value, "em"), 64)
+			return v * parseFontSize(n.Parent)
+		}
+		if size := parseLength(value); size > 0 {
+			return size
+		}
+		return 16
+	}
+	return 16
+}
+
+// parseLength parses a length in user units, "normal" and invalid values are 0.
+func parseLength(value string) float64 {
+	value = strings.TrimSpace(value)
+	units := map[string]float64{"px": 1, "pt": 4.0 / 3, "pc": 16, "mm": 96 / 25.4, "cm": 96 / 2.54, "in": 96}
+	scale := 1.0
+	for unit, s := range units {
+		if strings.HasSuffix(value, unit) {
+			value, scale = strings.TrimSuffix(value, unit), s
+			break
+		}
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	return v * scale
+}
+
+// parseLengths parses a list of lengths, such as the x attribute of text.
+func parseLengths(value string) []float64 {
+	var xs []float64
+	for _, field := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+		xs = append(xs, parseLength(field))
+	}
+	return xs
+}
@@ -0,0 +1,137 @@
+package svg
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+)
+
+func TestTextChars(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`<text>  a  b  </text>`, "a b"},
+		{"<text>a\nb\tc</text>", "ab c"},
+		{`<text>a <tspan> b </tspan> c</text>`, "a b c"},
+		{`<text>a<tspan style="display:none">b</tspan><g>c</g>d</text>`, "ad"},
+		{"<text xml:space=\"preserve\"> a\n b </text>", " a  b "},
+	}
+	for _, test := range tests {
+		doc, err := ParseString(test.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []rune
+		for _, c := range textChars(doc.Root()) {
+			got = append(got, c.r)
+		}
+		if string(got) != test.want {
+			t.Errorf("%s: got %q, want %q", test.in, string(got), test.want)
+		}
+	}
+}
+
+func TestTextLayout(t *testing.T) {
+	bounds := func(src string) map[string]Box {
+		doc := convertText(t, src)
+		boxes := map[string]Box{}
+		for id, node := range doc.IDs() {
+			boxes[id] = doc.Bounds(node)
+		}
+		return boxes
+	}
+
+	start := bounds(`<svg><text id="t" x="10" y="20" font-size="10">ll</text></svg>`)["t"]
+	// the baseline is at y, the outline may overshoot it a little
+	if start.Empty() || start.MinX < 10 || math.Abs(start.MaxY-20) > 0.5 || start.MinY > 20-5 {
+		t.Fatalf("text at 10,20 converted to %v", start)
+	}
+
+	middle := bounds(`<svg><text id="t" x="10" y="20" font-size="10" text-anchor="middle">ll</text></svg>`)["t"]
+	end := bounds(`<svg><text id="t" x="10" y="20" font-size="10" text-anchor="end">ll</text></svg>`)["t"]
+	width := start.MinX - end.MinX
+	if width <= start.Width() || !near(start.MinX-middle.MinX, width/2) {
+		t.Errorf("anchors moved start %v to middle %v and end %v", start, middle, end)
+	}
+
+	spaced := bounds(`<svg><text id="t" x="10" y="20" font-size="10" letter-spacing="5">ll</text></svg>`)["t"]
+	if !near(spaced.Width(), start.Width()+5) {
+		t.Errorf("letter-spacing changed width from %v to %v", start.Width(), spaced.Width())
+	}
+
+	larger := bounds(`<svg><g style="font-size:10px"><text id="t" x="10" y="20" style="font-size:200%">ll</text></g></svg>`)["t"]
+	if !near(larger.Height(), 2*start.Height()) {
+		t.Errorf("font-size 200%% changed height from %v to %v", start.Height(), larger.Height())
+	}
+
+	// a relative size is resolved against the parent of the element declaring it
+	inherited := bounds(`<svg font-size="20"><text x="10" y="20" font-size="50%"><tspan id="t">ll</tspan></text></svg>`)["t"]
+	if !near(inherited.Height(), start.Height()) {
+		t.Errorf("inherited font-size 50%% of 20 changed height from %v to %v", start.Height(), inherited.Height())
+	}
+
+	// an absolute y starts a new chunk, which is anchored separately
+	lines := bounds(`<svg><text id="t" x="10" y="20" font-size="10" text-anchor="end">ll<tspan id="s" y="40">ll</tspan></text></svg>`)
+	if !near(lines["t"].MinX, end.MinX) || !near(lines["s"].MinY, end.MinY+20) {
+		t.Errorf("lines at %v and %v, expected the first at %v", lines["t"], lines["s"], end)
+	}
+
+	// the first character of a tspan is positioned by its own x and dy
+	parts := bounds(`<svg><text id="t" x="10" y="20" font-size="10">l<tspan id="s" x="110" dy="5">l</tspan></text></svg>`)
+	first, second := parts["t"], parts["s"]
+	if !near(second.MinX, first.MinX+100) || !near(second.MinY, first.MinY+5) {
+		t.Errorf("tspan at %v, expected it 100, 5 from %v", second, first)
+	}
+}
+
+func TestTextNotes(t *testing.T) {
+	font, err := sfnt.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := ParseString(`<text style="font-family:Comic, 'Go';font-weight:bold">a<tspan rotate="10">b</tspan>` + "世" + `</text>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	missing, notes := textToPath(doc.Root(), newFontSet([]*sfnt.Font{font}))
+	if missing != "世" {
+		t.Errorf("got missing %q", missing)
+	}
+	want := []string{"ignored font-weight bold", "ignored rotate on tspan"}
+	if !reflect.DeepEqual(notes, want) {
+		t.Errorf("got notes %q, want %q", notes, want)
+	}
+
+	doc, err = ParseString(`<text style="font-family:Comic">a</text>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, notes = textToPath(doc.Root(), newFontSet([]*sfnt.Font{font}))
+	want = []string{"font-family Comic not in -font, used Go"}
+	if !reflect.DeepEqual(notes, want) {
+		t.Errorf("got notes %q, want %q", notes, want)
+	}
+}
+
+// convertText runs text-to-path with the Go font on src.
+func convertText(t *testing.T, src string) *Document {
+	t.Helper()
+	font, err := sfnt.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := ParseString(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Run(doc, []*Pass{TextToPathPass}, &Options{Fonts: []*sfnt.Font{font}})
+	return doc
+}
+
+// near reports whether a and b are equal up to the rounding of outlines.
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-2 }
@@ -0,0 +1,164 @@
+package svg
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Matrix is an affine transform [a b c d e f], which maps
+// (x, y) to (a*x + c*y + e, b*x + d*y + f).
+type Matrix [6]float64
+
+// Identity is the identity transform.
+var Identity = Matrix{1, 0, 0, 1, 0, 0}
+
+// ParseTransform parses a transform list, such as
+// "translate(10 20) rotate(45)", into a single matrix.
+func ParseTransform(s string) (Matrix, error) {
+	m := Identity
+	rest := strings.TrimSpace(s)
+	for rest != "" {
+		open := strings.IndexByte(rest, '(')
+		close := strings.IndexByte(rest, ')')
+		if open < 0 || close < open {
+			return m, fmt.Errorf("invalid transform %q", s)
+		}
+		name := strings.TrimSpace(rest[:open])
+		args, err := parseNumbers(rest[open+1 : close])
+		if err != nil {
+			return m, fmt.Errorf("invalid transform %q: %v", s, err)
+		}
+		rest = strings.TrimLeft(rest[close+1:], " \t\r\n,")
+
+		var t Matrix
+		switch {
+		case name == "matrix" && len(args) == 6:
+			copy(t[:], args)
+		case name == "translate" && len(args) == 1:
+			t = Matrix{1, 0, 0, 1, args[0], 0}
+		case name == "translate" && len(args) == 2:
+			t = Matrix{1, 0, 0, 1, args[0], args[1]}
+		case name == "scale" && len(args) == 1:
+			t = Matrix{args[0], 0, 0, args[0], 0, 0}
+		case name == "scale" && len(args) == 2:
+			t = Matrix{args[0], 0, 0, args[1], 0, 0}
+		case name == "rotate" && (len(args) == 1 || len(args) == 3):
+			sin, cos := math.Sincos(args[0] * math.Pi / 180)
+			t = Matrix{cos, sin, -sin, cos, 0, 0}
+			if len(args) == 3 {
+				cx, cy := args[1], args[2]
+				t = Matrix{1, 0, 0, 1, cx, cy}.Mul(t).Mul(Matrix{1, 0, 0, 1, -cx, -cy})
+			}
+		case name == "skewX" && len(args) == 1:
+			t = Matrix{1, 0, math.Tan(args[0] * math.Pi / 180), 1, 0, 0}
+		case name == "skewY" && len(args) == 1:
+			t = Matrix{1, math.Tan(args[0] * math.Pi / 180), 0, 1, 0, 0}
+		default:
+			return m, fmt.Errorf("invalid transform %q", s)
+		}
+		m = m.Mul(t)
+	}
+	return m, nil
+}
+
+// parseNumbers parses a comma or space separated list of numbers.
+func parseNumbers(s string) ([]float64, error) {
+	p := &pathScanner{s: s}
+	var xs []float64
+	for {
+		p.skip()
+		if p.eof() {
+			return xs, nil
+		}
+		v, err := p.number()
+		if err != nil {
+			return xs, err
+		}
+		xs = append(xs, v)
+	}
+}
+
+// Mul returns the transform that applies n first and then m.
+func (m Matrix) Mul(n Matrix) Matrix {
+	return Matrix{
+		m[0]*n[0] + m[2]*n[1],
+		m[1]*n[0] + m[3]*n[1],
+		m[0]*n[2] + m[2]*n[3],
+		m[1]*n[2] + m[3]*n[3],
+		m[0]*n[4] + m[2]*n[5] + m[4],
+		m[1]*n[4] + m[3]*n[5] + m[5],
+	}
+}
+
+// Apply transforms the point x, y.
+func (m Matrix) Apply(x, y float64) (float64, float64) {
+	return m[0]*x + m[2]*y + m[4], m[1]*x + m[3]*y + m[5]
+}
+
+// IsIdentity reports whether m doesn't change anything.
+func (m Matrix) IsIdentity() bool { return m == Identity }
+
+// IsTranslation reports whether m only moves.
+func (m Matrix) IsTranslation() bool {
+	return m[0] == 1 && m[1] == 0 && m[2] == 0 && m[3] == 1
+}
+
+// Format formats m in the shortest form, the identity is formatted as "".
+//
+// The translation is rounded to the number of decimals, the other
+// components use two more decimals, since errors in them are multiplied
+// by the coordinates. Negative decimals keep the full precision.
+func (m Matrix) Format(decimals int) string {
+	linear := decimals
+	if linear >= 0 {
+		linear += 2
+	}
+	translation := func() string {
+		if formatNumber(m[5], decimals) == "0" {
+			return formatNumber(m[4], decimals)
+		}
+		return joinNumbers(formatNumber(m[4], decimals), formatNumber(m[5], decimals))
+	}
+
+	a, b, c, d := formatNumber(m[0], linear), formatNumber(m[1], linear), formatNumber(m[2], linear), formatNumber(m[3], linear)
+	e, f := formatNumber(m[4], decimals), formatNumber(m[5], decimals)
+	forms := []string{"matrix(" + joinNumbers(a, b, c, d, e, f) + ")"}
+
+	translated := e != "0" || f != "0"
+	switch {
+	case b == "0" && c == "0" && a == "1" && d == "1":
+		if !translated {
+			return ""
+		}
+		forms = append(forms, "translate("+translation()+")")
+	case b == "0" && c == "0" && !translated:
+		if a == d {
+			forms = append(forms, "scale("+a+")")
+		} else {
+			forms = append(forms, "scale("+joinNumbers(a, d)+")")
+		}
+	case b == "0" && c == "0":
+		scale := "scale(" + a + ")"
+		if a != d {
+			scale = "scale(" + joinNumbers(a, d) + ")"
+		}
+		forms = append(forms, "translate("+translation()+")"+scale)
+	case !translated && a == d && formatNumber(m[1], linear) == formatNumber(-m[2], linear) &&
+		math.Abs(m[0]*m[0]+m[1]*m[1]-1) < 1e-9:
+		angle := math.Atan2(m[1], m[0]) * 180 / math.Pi
+		rotate := "rotate(" + formatNumber(angle, linear) + ")"
+		// at full precision the rotation must be exact
+		if r, err := ParseTransform(rotate); decimals >= 0 || err == nil && r == m {
+			forms = append(forms, rotate)
+		}
+	}
+
+	best := forms[0]
+	for _, form := range forms[1:] {
+		if len(form) < len(best) {
+			best = form
+		}
+	}
+	return best
+}
@@ -0,0 +1,95 @@
+package svg
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseTransform(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Matrix
+	}{
+		{"", Identity},
+		{"translate(10)", Matrix{1, 0, 0, 1, 10, 0}},
+		{"translate(10,-5) scale(2)", Matrix{2, 0, 0, 2, 10, -5}},
+		{"scale(2, 3)translate(1 1)", Matrix{2, 0, 0, 3, 2, 3}},
+		{"rotate(90)", Matrix{0, 1, -1, 0, 0, 0}},
+		{"rotate(180 5 5)", Matrix{-1, 0, 0, -1, 10, 10}},
+		{"skewX(45)", Matrix{1, 0, 1, 1, 0, 0}},
+		{"skewY(45)", Matrix{1, 1, 0, 1, 0, 0}},
+		{" matrix(1,2,3,4,5,6) ", Matrix{1, 2, 3, 4, 5, 6}},
+	}
+	for _, test := range tests {
+		got, err := ParseTransform(test.in)
+		if err != nil {
+			t.Errorf("%q: %v", test.in, err)
+			continue
+		}
+		if !sameMatrix(got, test.want) {
+			t.Errorf("%q: got %v, want %v", test.in, got, test.want)
+		}
+	}
+
+	for _, bad := range []string{"translate", "scale(1,2,3)", "foo(1)", "rotate(1 2)", "translate(1))"} {
+		if m, err := ParseTransform(bad); err == nil {
+			t.Errorf("%q: expected an error, got %v", bad, m)
+		}
+	}
+}
+
+func TestFormatTransform(t *testing.T) {
+	tests := []struct {
+		in       string
+		decimals int
+		want     string
+	}{
+		{"matrix(1,0,0,1,0,0)", -1, ""},
+		{"matrix(1,0,0,1,10,0)", -1, "translate(10)"},
+		{"matrix(1,0,0,1,10,20)", -1, "translate(10 20)"},
+		{"matrix(2,0,0,2,0,0)", -1, "scale(2)"},
+		{"matrix(2,0,0,3,0,0)", -1, "scale(2 3)"},
+		// the shortest form wins
+		{"translate(10,20) scale(2)", -1, "matrix(2 0 0 2 10 20)"},
+		{"rotate(30)", 1, "rotate(30)"},
+		{"translate(0.04,0.01)", 1, ""},
+		{"translate(1.234,5)", 1, "translate(1.2 5)"},
+		{"matrix(0.5,0.25,-0.25,0.5,1,2)", -1, "matrix(.5.25-.25.5 1 2)"},
+	}
+	for _, test := range tests {
+		m, err := ParseTransform(test.in)
+		if err != nil {
+			t.Errorf("%q: %v", test.in, err)
+			continue
+		}
+		if got := m.Format(test.decimals); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestMatrix(t *testing.T) {
+	translate := Matrix{1, 0, 0, 1, 10, 0}
+	scale := Matrix{2, 0, 0, 2, 0, 0}
+
+	// Mul applies the argument first
+	if x, y := translate.Mul(scale).Apply(1, 1); x != 12 || y != 2 {
+		t.Errorf("translate after scale moved 1,1 to %v,%v", x, y)
+	}
+	if x, y := scale.Mul(translate).Apply(1, 1); x != 22 || y != 2 {
+		t.Errorf("scale after translate moved 1,1 to %v,%v", x, y)
+	}
+	if !translate.IsTranslation() || scale.IsTranslation() || !Identity.IsIdentity() {
+		t.Errorf("wrong classification")
+	}
+}
+
+// sameMatrix reports whether a and b are equal up to floating point errors.
+func sameMatrix(a, b Matrix) bool {
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
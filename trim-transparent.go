@@ -0,0 +1,195 @@
+// trim-transparent crops images to the bounding box of their visible pixels.
+//
+//	go run trim-transparent.go -padding 2 icon/emoji/*.png
+//	go run trim-transparent.go -union -out trimmed frames/*.png
+//
+// With -union every image is cropped to the same box, so that frames of an
+// animation stay aligned. Other formats than PNG are only read with -out,
+// the output is always PNG.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"image/gif"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	_ "image/jpeg"
+	_ "image/png"
+
+	"golang.org/x/image/draw"
+
+	"github.com/egonelbre/gophers/internal/atomicfile"
+	"github.com/egonelbre/gophers/pngopt"
+)
+
+var (
+	padding = flag.Int("padding", 0, "transparent padding around the trimmed image")
+	union   = flag.Bool("union", false, "trim all images to the union of their bounding boxes")
+	outdir  = flag.String("out", "", "output directory, by default files are modified in place")
+	dryrun  = flag.Bool("dry-run", false, "only report the trimmed bounds")
+)
+
+// AlphaBounds returns the smallest rectangle containing all pixels with non-zero alpha.
+func AlphaBounds(m image.Image) image.Rectangle {
+	b := m.Bounds()
+	r := image.Rectangle{}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := m.At(x, y).RGBA(); a != 0 {
+				r = r.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return r
+}
+
+// Crop returns the area r of m, pixels outside of m are transparent.
+func Crop(m image.Image, r image.Rectangle) image.Image {
+	target := image.NewNRGBA(image.Rectangle{image.ZP, r.Size()})
+	draw.Draw(target, target.Bounds(), m, r.Min, draw.Src)
+	return target
+}
+
+// LoadImage loads a single frame image and returns its format.
+// Animated GIFs are rejected, since only their first frame would be trimmed.
+func LoadImage(path string) (image.Image, string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	m, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	if format == "gif" {
+		anim, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, "", err
+		}
+		if len(anim.Image) > 1 {
+			return nil, "", fmt.Errorf("%v: animated GIF with %d frames is not supported", path, len(anim.Image))
+		}
+	}
+	return m, format, nil
+}
+
+func ReplaceExt(path, ext string) string {
+	return path[:len(path)-len(filepath.Ext(path))] + ext
+}
+
+// CommonDir returns the deepest directory containing all paths.
+func CommonDir(paths []string) (string, error) {
+	var common []string
+	for i, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return "", err
+		}
+		parts := strings.Split(filepath.Dir(abs), string(filepath.Separator))
+		if i == 0 {
+			common = parts
+			continue
+		}
+		n := 0
+		for n < len(common) && n < len(parts) && common[n] == parts[n] {
+			n++
+		}
+		common = common[:n]
+	}
+	dir := strings.Join(common, string(filepath.Separator))
+	if dir == "" {
+		dir = string(filepath.Separator)
+	}
+	return dir, nil
+}
+
+// OutputName returns where file is written in -out, keeping
+// its path relative to base, so that equal names don't collide.
+func OutputName(base, file string) string {
+	rel := filepath.Base(file)
+	if abs, err := filepath.Abs(file); err == nil {
+		if r, err := filepath.Rel(base, abs); err == nil {
+			rel = r
+		}
+	}
+	return filepath.Join(*outdir, ReplaceExt(rel, ".png"))
+}
+
+func main() {
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *padding < 0 {
+		check(fmt.Errorf("-padding must not be negative, got %d", *padding))
+	}
+
+	files := []string{}
+	for _, arg := range flag.Args() {
+		matches, err := filepath.Glob(arg)
+		check(err)
+		files = append(files, matches...)
+	}
+
+	images := make([]image.Image, len(files))
+	boxes := make([]image.Rectangle, len(files))
+	common := image.Rectangle{}
+	for i, file := range files {
+		m, format, err := LoadImage(file)
+		check(err)
+		if format != "png" && *outdir == "" {
+			// writing a PNG next to the original wouldn't trim it
+			check(fmt.Errorf("%v: only PNG files can be trimmed in place, use -out to write %s as PNG", file, format))
+		}
+		images[i] = m
+		boxes[i] = AlphaBounds(m)
+		common = common.Union(boxes[i])
+	}
+
+	base, err := CommonDir(files)
+	check(err)
+
+	for i, file := range files {
+		box := boxes[i]
+		if *union {
+			box = common
+		}
+		if box.Empty() {
+			fmt.Printf("%v: fully transparent, skipping\n", file)
+			continue
+		}
+		box = box.Inset(-*padding)
+
+		outname := ReplaceExt(file, ".png")
+		if *outdir != "" {
+			outname = OutputName(base, file)
+		}
+
+		fmt.Printf("%v: %v -> %v\n", file, images[i].Bounds(), box)
+		if *dryrun {
+			continue
+		}
+
+		data, err := pngopt.DefaultOptions.Encode(Crop(images[i], box))
+		check(err)
+		check(os.MkdirAll(filepath.Dir(outname), 0755))
+		check(atomicfile.WriteFile(outname, data))
+	}
+}
+
+func check(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"flag"
 	"fmt"
 	"image"
@@ -8,19 +9,51 @@ import (
 	"image/gif"
 	"image/png"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	_ "image/jpeg"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/webp"
 
 	"golang.org/x/image/draw"
+
+	"github.com/egonelbre/gophers/internal/atomicfile"
+	"github.com/egonelbre/gophers/palette"
 )
 
 var (
 	width       = flag.Int("width", 506, "target min width")
 	height      = flag.Int("height", 128, "target min height")
 	repeat      = flag.Int("repeat", 3, "repeat count")
-	transparent = flag.Bool("transparent", false, "transparent background")
+	transparent = flag.Bool("transparent", false, "transparent background, shorthand for -background transparent")
+	bgspec      = flag.String("background", "", "background: transparent, #rrggbb[aa], checker:#a,#b[,size], gradient:#from,#to[,horizontal] or image:path")
 	duplicate   = flag.Bool("duplicate", false, "use duplication instead of repeating animation")
 	duration    = flag.Int("duration", 0, "override frame duration")
+
+	speed    = flag.Float64("speed", 1, "animation speed multiplier")
+	delays   = flag.String("delays", "", "per-frame delays in 1/100s by source frame, e.g. 0:50,3-5:20")
+	trim     = flag.String("frames", "", "keep only a frame range of the source, e.g. 2-10 or 4-")
+	reverse  = flag.Bool("reverse", false, "play animation in reverse")
+	pingpong = flag.Bool("pingpong", false, "play animation forward and then backward")
+	hold     = flag.Int("hold", 0, "extra delay in 1/100s for the last frame")
+	loops    = flag.Int("loops", -1, "number of times to play the animation, 0 plays forever, -1 keeps the source setting")
+
+	anchor  = flag.String("anchor", "center", "placement anchor: top-left, top, top-right, left, center, right, bottom-left, bottom, bottom-right")
+	offsetf = flag.String("offset", "0,0", "offset in pixels added after anchoring, as x,y")
+	margin  = flag.String("margin", "0", "margin inside the canvas in pixels or percent, as all or horizontal,vertical, e.g. 10% or 8,5%")
+	scaleUp = flag.Bool("scale-up", false, "integer scale the source up to fill the target before padding")
+
+	outdir   = flag.String("out", "", "output directory, enables batch mode over globs and directories")
+	naming   = flag.String("name", "{name}-twitter{ext}", "output name template for batch mode")
+	parallel = flag.Int("parallel", runtime.NumCPU(), "number of files processed in parallel")
 )
 
 func handleGif(infile io.Reader, outfile io.Writer) error {
@@ -31,14 +64,12 @@ func handleGif(infile io.Reader, outfile io.Writer) error {
 		return fmt.Errorf("failed to decode gif: %v", err)
 	}
 
-	size := image.Pt(source.Config.Width, source.Config.Height)
-	if size.X < *width {
-		size.X = *width
-	}
-	if size.Y < *height {
-		size.Y = *height
+	if err := timing.Apply(source); err != nil {
+		return err
 	}
 
+	size, scale, offset := layout.Place(image.Pt(source.Config.Width, source.Config.Height))
+
 	var target gif.GIF
 	target.Config = source.Config
 	target.Config.Width = size.X
@@ -47,24 +78,29 @@ func handleGif(infile io.Reader, outfile io.Writer) error {
 	target.LoopCount = source.LoopCount
 	target.BackgroundIndex = source.BackgroundIndex
 
-	offset := image.Pt(
-		size.X/2-source.Config.Width/2,
-		size.Y/2-source.Config.Height/2,
-	)
+	bounds := image.Rectangle{image.ZP, size}
+	bg := background.Render(size)
+	var bgColors []color.NRGBA
+	if bg != nil {
+		bgColors = backgroundColors(background, bg)
+	}
 
 	for i, m := range source.Image {
-		d := image.NewPaletted(image.Rectangle{image.ZP, size}, m.Palette)
-		if !*transparent {
+		palette := append(color.Palette{}, m.Palette...)
+		d := image.NewPaletted(bounds, palette)
+		if bg != nil {
+			extendPalette(d, bgColors)
+			draw.Draw(d, bounds, bg, image.ZP, draw.Src)
+		} else {
+			clear := transparentIndex(d)
 			for k := range d.Pix {
-				d.Pix[k] = m.Pix[0]
+				d.Pix[k] = clear
 			}
 		}
-		draw.Draw(d, m.Bounds().Add(offset), m, image.ZP, draw.Over)
+		m = scalePaletted(m, scale)
+		draw.Draw(d, m.Bounds().Add(offset), m, m.Bounds().Min, draw.Over)
 
 		delay := source.Delay[i]
-		if *duration > 0 {
-			delay = *duration
-		}
 
 		if *duplicate {
 			target.Image = append(target.Image, d)
@@ -88,7 +124,7 @@ func handleGif(infile io.Reader, outfile io.Writer) error {
 		}
 	}
 
-	if !*duplicate {
+	if !*duplicate && len(source.Image) > 1 {
 		n := len(source.Image)
 		for k := 1; k < *repeat; k++ {
 			for i := 0; i < n; i++ {
@@ -109,30 +145,27 @@ func handleGif(infile io.Reader, outfile io.Writer) error {
 	return nil
 }
 
-func handlePng(infile io.Reader, outfile io.Writer) error {
-	source, err := png.Decode(infile)
+// handleImage pads a still image, format is the sniffed input format
+// used in errors.
+func handleImage(infile io.Reader, outfile io.Writer, format string) error {
+	source, _, err := image.Decode(infile)
 	if err != nil {
-		return fmt.Errorf("failed to decode png: %v", err)
+		return fmt.Errorf("failed to decode %v: %v", format, err)
 	}
 
-	size := source.Bounds().Size()
-	if size.X < *width {
-		size.X = *width
+	size, scale, offset := layout.Place(source.Bounds().Size())
+	if scale > 1 {
+		r := source.Bounds()
+		scaled := image.NewRGBA(image.Rect(0, 0, r.Dx()*scale, r.Dy()*scale))
+		draw.NearestNeighbor.Scale(scaled, scaled.Bounds(), source, r, draw.Src, nil)
+		source = scaled
 	}
-	if size.Y < *height {
-		size.Y = *height
-	}
-
-	offset := image.Pt(
-		size.X/2-source.Bounds().Dx()/2,
-		size.Y/2-source.Bounds().Dy()/2,
-	)
 
 	target := image.NewRGBA(image.Rectangle{image.ZP, size})
-	if !*transparent {
-		draw.Draw(target, target.Bounds(), &image.Uniform{color.White}, image.ZP, draw.Over)
+	if bg := background.Render(size); bg != nil {
+		draw.Draw(target, target.Bounds(), bg, image.ZP, draw.Over)
 	}
-	draw.Draw(target, source.Bounds().Add(offset), source, image.ZP, draw.Over)
+	draw.Draw(target, source.Bounds().Add(offset), source, source.Bounds().Min, draw.Over)
 
 	err = png.Encode(outfile, target)
 	if err != nil {
@@ -141,40 +174,901 @@ func handlePng(infile io.Reader, outfile io.Writer) error {
 
 	return nil
 }
+
+// scalePaletted scales m up by an integer factor, keeping palette indices.
+func scalePaletted(m *image.Paletted, scale int) *image.Paletted {
+	if scale <= 1 {
+		return m
+	}
+
+	r := m.Bounds()
+	d := image.NewPaletted(image.Rectangle{r.Min.Mul(scale), r.Max.Mul(scale)}, m.Palette)
+	for y := d.Rect.Min.Y; y < d.Rect.Max.Y; y++ {
+		for x := d.Rect.Min.X; x < d.Rect.Max.X; x++ {
+			d.SetColorIndex(x, y, m.ColorIndexAt(floorDiv(x, scale), floorDiv(y, scale)))
+		}
+	}
+	return d
+}
+
+func floorDiv(a, b int) int {
+	if a < 0 {
+		return (a - b + 1) / b
+	}
+	return a / b
+}
+
+// Layout describes where the source is placed on the padded canvas.
+type Layout struct {
+	// Anchor is 0 for start, 1 for center and 2 for end on each axis.
+	Anchor  image.Point
+	Offset  image.Point
+	Margin  [2]Length
+	ScaleUp bool
+}
+
+// Length is either in pixels or in percent of the canvas size.
+type Length struct {
+	Value   float64
+	Percent bool
+}
+
+func (length Length) Pixels(total int) int {
+	if length.Percent {
+		return int(length.Value*float64(total)/100 + 0.5)
+	}
+	return int(length.Value)
+}
+
+// layout is parsed from -anchor, -offset, -margin and -scale-up in main.
+var layout = Layout{Anchor: image.Pt(1, 1)}
+
+// Place returns the canvas size for a source of size src,
+// the integer scale applied to the source and its offset in the canvas.
+func (layout *Layout) Place(src image.Point) (canvas image.Point, scale int, offset image.Point) {
+	canvas = image.Pt(*width, *height)
+	if canvas.X < src.X {
+		canvas.X = src.X
+	}
+	if canvas.Y < src.Y {
+		canvas.Y = src.Y
+	}
+
+	margin := image.Pt(layout.Margin[0].Pixels(canvas.X), layout.Margin[1].Pixels(canvas.Y))
+	if canvas.X < src.X+2*margin.X {
+		canvas.X = src.X + 2*margin.X
+	}
+	if canvas.Y < src.Y+2*margin.Y {
+		canvas.Y = src.Y + 2*margin.Y
+	}
+	area := image.Rectangle{margin, canvas.Sub(margin)}
+
+	scale = 1
+	if layout.ScaleUp && src.X > 0 && src.Y > 0 {
+		scale = area.Dx() / src.X
+		if s := area.Dy() / src.Y; s < scale {
+			scale = s
+		}
+		if scale < 1 {
+			scale = 1
+		}
+	}
+
+	scaled := src.Mul(scale)
+	offset = image.Pt(
+		area.Min.X+(area.Dx()-scaled.X)*layout.Anchor.X/2,
+		area.Min.Y+(area.Dy()-scaled.Y)*layout.Anchor.Y/2,
+	).Add(layout.Offset)
+
+	return canvas, scale, offset
+}
+
+func parseLayout() (Layout, error) {
+	var layout Layout
+
+	anchors := map[string]image.Point{
+		"top-left": {0, 0}, "top": {1, 0}, "top-center": {1, 0}, "top-right": {2, 0},
+		"left": {0, 1}, "center-left": {0, 1}, "center": {1, 1}, "right": {2, 1}, "center-right": {2, 1},
+		"bottom-left": {0, 2}, "bottom": {1, 2}, "bottom-center": {1, 2}, "bottom-right": {2, 2},
+	}
+	a, ok := anchors[*anchor]
+	if !ok {
+		return layout, fmt.Errorf("unknown anchor %q", *anchor)
+	}
+	layout.Anchor = a
+
+	var err error
+	if _, err = fmt.Sscanf(*offsetf, "%d,%d", &layout.Offset.X, &layout.Offset.Y); err != nil {
+		return layout, fmt.Errorf("invalid offset %q: %v", *offsetf, err)
+	}
+
+	parts := strings.Split(*margin, ",")
+	if len(parts) > 2 {
+		return layout, fmt.Errorf("invalid margin %q", *margin)
+	}
+	for i := range layout.Margin {
+		part := parts[0]
+		if i < len(parts) {
+			part = parts[i]
+		}
+		layout.Margin[i], err = parseLength(part)
+		if err != nil {
+			return layout, err
+		}
+	}
+
+	layout.ScaleUp = *scaleUp
+	return layout, nil
+}
+
+func parseLength(s string) (Length, error) {
+	s = strings.TrimSpace(s)
+	length := Length{Percent: strings.HasSuffix(s, "%")}
+	v, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	if err != nil || v < 0 {
+		return length, fmt.Errorf("invalid length %q", s)
+	}
+	length.Value = v
+	return length, nil
+}
+
+// Timing describes how the frames and delays of an animation are edited.
+type Timing struct {
+	Duration int
+	Speed    float64
+	Delays   map[int]int
+
+	// From and To select the source frames to keep, To < 0 means until the end.
+	From, To int
+
+	Reverse  bool
+	PingPong bool
+	Hold     int
+
+	// Loops is the number of times to play the animation,
+	// 0 plays forever and -1 keeps the source setting.
+	Loops int
+}
+
+// timing is parsed from the animation flags in main.
+var timing = Timing{Speed: 1, To: -1, Loops: -1}
+
+// Reorders reports whether frames are dropped or played out of order.
+func (timing *Timing) Reorders() bool {
+	return timing.From > 0 || timing.To >= 0 || timing.Reverse || timing.PingPong
+}
+
+// Apply edits the frames, delays and loop count of g in place.
+func (timing *Timing) Apply(g *gif.GIF) error {
+	for i := range g.Delay {
+		if timing.Duration > 0 {
+			g.Delay[i] = timing.Duration
+		}
+		if delay, ok := timing.Delays[i]; ok {
+			g.Delay[i] = delay
+		}
+		if timing.Speed > 0 && timing.Speed != 1 {
+			g.Delay[i] = int(float64(g.Delay[i])/timing.Speed + 0.5)
+		}
+	}
+
+	if timing.Reorders() {
+		// frames may depend on the previous ones, make each of them complete
+		coalesce(g)
+
+		to := timing.To
+		if to < 0 || to >= len(g.Image) {
+			to = len(g.Image) - 1
+		}
+		if timing.From > to {
+			return fmt.Errorf("frame range %d-%d is outside of %d frames", timing.From, timing.To, len(g.Image))
+		}
+
+		order := []int{}
+		for i := timing.From; i <= to; i++ {
+			order = append(order, i)
+		}
+		if timing.Reverse {
+			for i, k := 0, len(order)-1; i < k; i, k = i+1, k-1 {
+				order[i], order[k] = order[k], order[i]
+			}
+		}
+		if timing.PingPong {
+			for i := len(order) - 2; i > 0; i-- {
+				order = append(order, order[i])
+			}
+		}
+
+		images, delay, disposal := g.Image, g.Delay, g.Disposal
+		g.Image, g.Delay, g.Disposal = nil, nil, nil
+		for _, i := range order {
+			g.Image = append(g.Image, images[i])
+			g.Delay = append(g.Delay, delay[i])
+			g.Disposal = append(g.Disposal, disposal[i])
+		}
+	}
+
+	if n := len(g.Delay); n > 0 {
+		g.Delay[n-1] += timing.Hold
+	}
+
+	switch {
+	case timing.Loops == 0:
+		g.LoopCount = 0
+	case timing.Loops == 1:
+		g.LoopCount = -1
+	case timing.Loops > 1:
+		g.LoopCount = timing.Loops - 1
+	}
+
+	return nil
+}
+
+// coalesce replaces every frame of g with the complete image shown at that
+// point of the animation, so that frames can be reordered or dropped.
+func coalesce(g *gif.GIF) {
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	canvas := image.NewRGBA(bounds)
+
+	if len(g.Disposal) < len(g.Image) {
+		g.Disposal = append(g.Disposal, make([]byte, len(g.Image)-len(g.Disposal))...)
+	}
+
+	for i, m := range g.Image {
+		var previous *image.RGBA
+		if g.Disposal[i] == gif.DisposalPrevious {
+			previous = image.NewRGBA(bounds)
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, m.Bounds(), m, m.Bounds().Min, draw.Over)
+
+		full := image.NewPaletted(bounds, append(color.Palette{}, m.Palette...))
+		clear := transparentIndex(full)
+		draw.Draw(full, bounds, canvas, image.ZP, draw.Src)
+		// draw only picks the closest color for transparent pixels
+		for y := 0; y < bounds.Dy(); y++ {
+			for x := 0; x < bounds.Dx(); x++ {
+				if canvas.Pix[canvas.PixOffset(x, y)+3] == 0 {
+					full.Pix[full.PixOffset(x, y)] = clear
+				}
+			}
+		}
+		g.Image[i] = full
+
+		switch g.Disposal[i] {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, m.Bounds(), image.Transparent, image.ZP, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+		g.Disposal[i] = gif.DisposalBackground
+	}
+}
+
+func parseTiming() (Timing, error) {
+	timing := Timing{
+		Duration: *duration,
+		Speed:    *speed,
+		Delays:   map[int]int{},
+		To:       -1,
+		Reverse:  *reverse,
+		PingPong: *pingpong,
+		Hold:     *hold,
+		Loops:    *loops,
+	}
+
+	if timing.Speed <= 0 {
+		return timing, fmt.Errorf("speed must be positive, got %v", timing.Speed)
+	}
+
+	if *delays != "" {
+		for _, entry := range strings.Split(*delays, ",") {
+			parts := strings.Split(entry, ":")
+			if len(parts) != 2 {
+				return timing, fmt.Errorf("invalid delay %q, expected frame:delay", entry)
+			}
+			from, to, err := parseRange(parts[0])
+			if err != nil {
+				return timing, err
+			}
+			if to < 0 {
+				return timing, fmt.Errorf("open frame range %q in delays", parts[0])
+			}
+			delay, err := strconv.Atoi(parts[1])
+			if err != nil || delay < 0 {
+				return timing, fmt.Errorf("invalid delay %q", parts[1])
+			}
+			for i := from; i <= to; i++ {
+				timing.Delays[i] = delay
+			}
+		}
+	}
+
+	if *trim != "" {
+		var err error
+		timing.From, timing.To, err = parseRange(*trim)
+		if err != nil {
+			return timing, err
+		}
+	}
+
+	return timing, nil
+}
+
+// parseRange parses "n", "from-to" or "from-", where an open end is returned as -1.
+func parseRange(s string) (from, to int, err error) {
+	parts := strings.SplitN(s, "-", 2)
+	from, err = strconv.Atoi(parts[0])
+	if err != nil || from < 0 {
+		return 0, 0, fmt.Errorf("invalid frame range %q", s)
+	}
+	if len(parts) == 1 {
+		return from, from, nil
+	}
+	if parts[1] == "" {
+		return from, -1, nil
+	}
+	to, err = strconv.Atoi(parts[1])
+	if err != nil || to < from {
+		return 0, 0, fmt.Errorf("invalid frame range %q", s)
+	}
+	return from, to, nil
+}
+
+// backgroundColors returns the distinct colors of bg, rendered from spec,
+// in the order they appear.
+func backgroundColors(spec Background, bg image.Image) []color.NRGBA {
+	nrgba := func(c color.Color) color.NRGBA {
+		return color.NRGBAModel.Convert(c).(color.NRGBA)
+	}
+
+	switch spec := spec.(type) {
+	case Uniform:
+		return []color.NRGBA{nrgba(spec.Color)}
+	case Checker:
+		return []color.NRGBA{nrgba(spec.A), nrgba(spec.B)}
+	}
+
+	var colors []color.NRGBA
+	seen := map[color.NRGBA]bool{}
+	r := bg.Bounds()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := nrgba(bg.At(x, y))
+			if !seen[c] {
+				seen[c] = true
+				colors = append(colors, c)
+			}
+		}
+	}
+	return colors
+}
+
+// extendPalette adds colors to the palette of d,
+// as long as there is room left in the palette.
+func extendPalette(d *image.Paletted, colors []color.NRGBA) {
+	known := map[color.NRGBA]bool{}
+	for _, c := range d.Palette {
+		known[color.NRGBAModel.Convert(c).(color.NRGBA)] = true
+	}
+	for _, c := range colors {
+		if len(d.Palette) >= 256 {
+			return
+		}
+		if !known[c] {
+			known[c] = true
+			d.Palette = append(d.Palette, c)
+		}
+	}
+}
+
+// transparentIndex returns the index of a fully transparent color in
+// the palette of d, adding one when necessary.
+func transparentIndex(d *image.Paletted) uint8 {
+	for i, c := range d.Palette {
+		if _, _, _, a := c.RGBA(); a == 0 {
+			return uint8(i)
+		}
+	}
+	if len(d.Palette) < 256 {
+		d.Palette = append(d.Palette, color.Transparent)
+		return uint8(len(d.Palette) - 1)
+	}
+	return uint8(d.Palette.Index(color.Transparent))
+}
+
+// Background renders the canvas behind the padded image.
+type Background interface {
+	// Render returns the background for a canvas of size,
+	// nil means transparent.
+	Render(size image.Point) image.Image
+}
+
+type Transparent struct{}
+
+func (Transparent) Render(size image.Point) image.Image { return nil }
+
+type Uniform struct{ Color color.Color }
+
+func (bg Uniform) Render(size image.Point) image.Image {
+	return &image.Uniform{bg.Color}
+}
+
+type Checker struct {
+	A, B color.Color
+	Size int
+}
+
+func (bg Checker) Render(size image.Point) image.Image {
+	m := image.NewRGBA(image.Rectangle{image.ZP, size})
+	for y := 0; y < size.Y; y++ {
+		for x := 0; x < size.X; x++ {
+			if (x/bg.Size+y/bg.Size)%2 == 0 {
+				m.Set(x, y, bg.A)
+			} else {
+				m.Set(x, y, bg.B)
+			}
+		}
+	}
+	return m
+}
+
+type Gradient struct {
+	From, To   color.Color
+	Horizontal bool
+}
+
+func (bg Gradient) Render(size image.Point) image.Image {
+	m := image.NewNRGBA(image.Rectangle{image.ZP, size})
+	from := color.NRGBAModel.Convert(bg.From).(color.NRGBA)
+	to := color.NRGBAModel.Convert(bg.To).(color.NRGBA)
+
+	lerp := func(a, b uint8, p, n int) uint8 {
+		if n <= 1 {
+			return a
+		}
+		return uint8((int(a)*(n-1-p) + int(b)*p) / (n - 1))
+	}
+
+	for y := 0; y < size.Y; y++ {
+		for x := 0; x < size.X; x++ {
+			p, n := y, size.Y
+			if bg.Horizontal {
+				p, n = x, size.X
+			}
+			m.SetNRGBA(x, y, color.NRGBA{
+				R: lerp(from.R, to.R, p, n),
+				G: lerp(from.G, to.G, p, n),
+				B: lerp(from.B, to.B, p, n),
+				A: lerp(from.A, to.A, p, n),
+			})
+		}
+	}
+	return m
+}
+
+// Backdrop scales an image to cover the whole canvas.
+type Backdrop struct{ Image image.Image }
+
+func (bg Backdrop) Render(size image.Point) image.Image {
+	src := bg.Image.Bounds()
+	scale := float64(size.X) / float64(src.Dx())
+	if s := float64(size.Y) / float64(src.Dy()); s > scale {
+		scale = s
+	}
+
+	scaled := image.Pt(int(float64(src.Dx())*scale+0.5), int(float64(src.Dy())*scale+0.5))
+	offset := image.Pt((size.X-scaled.X)/2, (size.Y-scaled.Y)/2)
+
+	m := image.NewRGBA(image.Rectangle{image.ZP, size})
+	draw.CatmullRom.Scale(m, image.Rectangle{offset, offset.Add(scaled)}, bg.Image, src, draw.Src, nil)
+	return m
+}
+
+// background is parsed from -background and -transparent in main.
+var background Background = Uniform{color.White}
+
+func parseBackground(spec string) (Background, error) {
+	kind, args := spec, ""
+	if i := strings.Index(spec, ":"); i >= 0 {
+		kind, args = spec[:i], spec[i+1:]
+	}
+
+	switch kind {
+	case "transparent", "none":
+		return Transparent{}, nil
+	case "checker":
+		parts := strings.Split(args, ",")
+		if len(parts) < 2 || len(parts) > 3 {
+			return nil, fmt.Errorf("expected checker:#a,#b[,size], got %q", spec)
+		}
+		a, err := palette.ParseColor(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		b, err := palette.ParseColor(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		size := 8
+		if len(parts) == 3 {
+			size, err = strconv.Atoi(parts[2])
+			if err != nil || size <= 0 {
+				return nil, fmt.Errorf("invalid checker size %q", parts[2])
+			}
+		}
+		return Checker{A: a, B: b, Size: size}, nil
+	case "gradient":
+		parts := strings.Split(args, ",")
+		if len(parts) < 2 || len(parts) > 3 {
+			return nil, fmt.Errorf("expected gradient:#from,#to[,horizontal], got %q", spec)
+		}
+		from, err := palette.ParseColor(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		to, err := palette.ParseColor(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		horizontal := false
+		if len(parts) == 3 {
+			switch parts[2] {
+			case "horizontal":
+				horizontal = true
+			case "vertical":
+			default:
+				return nil, fmt.Errorf("invalid gradient direction %q", parts[2])
+			}
+		}
+		return Gradient{From: from, To: to, Horizontal: horizontal}, nil
+	case "image":
+		file, err := os.Open(args)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		m, _, err := image.Decode(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode backdrop %v: %v", args, err)
+		}
+		return Backdrop{Image: m}, nil
+	}
+
+	c, err := palette.ParseColor(spec)
+	if err != nil {
+		return nil, err
+	}
+	return Uniform{c}, nil
+}
+
+// twitterify detects the input format from its content and
+// returns the padded image, GIF for animations and PNG for everything else.
+// ext is the file extension matching the output format.
+func twitterify(data []byte) (output []byte, ext string, err error) {
+	_, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("unsupported input: %v", err)
+	}
+
+	var buf bytes.Buffer
+	ext = formatExt(format)
+	switch format {
+	case "gif":
+		err = handleGif(bytes.NewReader(data), &buf)
+	default:
+		err = handleImage(bytes.NewReader(data), &buf, format)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	return buf.Bytes(), ext, nil
+}
+
+// imageExts lists extensions picked up when a directory is given in batch mode.
+var imageExts = map[string]bool{
+	".gif": true, ".png": true, ".jpg": true, ".jpeg": true, ".webp": true, ".bmp": true,
+}
+
+// expandInputs resolves globs and directories into a sorted list of files.
+func expandInputs(args []string) ([]string, error) {
+	seen := map[string]bool{}
+	files := []string{}
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+
+	for _, arg := range args {
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %q", arg)
+		}
+
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, err
+			}
+			if !info.IsDir() {
+				add(match)
+				continue
+			}
+
+			infos, err := ioutil.ReadDir(match)
+			if err != nil {
+				return nil, err
+			}
+			for _, info := range infos {
+				ext := strings.ToLower(filepath.Ext(info.Name()))
+				if !info.IsDir() && imageExts[ext] {
+					add(filepath.Join(match, info.Name()))
+				}
+			}
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// outputName expands the -name template for input, keeping its directory
+// relative to base, {name} is the input name without extension and {ext}
+// the output extension.
+func outputName(base, input, ext string) string {
+	dir, name := ".", filepath.Base(input)
+	if abs, err := filepath.Abs(input); err == nil {
+		if rel, err := filepath.Rel(base, filepath.Dir(abs)); err == nil {
+			dir = rel
+		}
+	}
+	name = name[:len(name)-len(filepath.Ext(name))]
+	return filepath.Join(*outdir, dir, strings.NewReplacer(
+		"{name}", name,
+		"{ext}", ext,
+	).Replace(*naming))
+}
+
+// formatExt returns the extension of the output written for an input format,
+// animations stay gifs and everything else becomes a png.
+func formatExt(format string) string {
+	if format == "gif" {
+		return ".gif"
+	}
+	return ".png"
+}
+
+// outputExt returns the extension twitterify uses for the input file.
+func outputExt(input string) string {
+	file, err := os.Open(input)
+	if err != nil {
+		return ".png"
+	}
+	defer file.Close()
+
+	_, format, _ := image.DecodeConfig(file)
+	return formatExt(format)
+}
+
+// commonDir returns the deepest directory containing all paths.
+func commonDir(paths []string) (string, error) {
+	var common []string
+	for i, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return "", err
+		}
+		parts := strings.Split(filepath.Dir(abs), string(filepath.Separator))
+		if i == 0 {
+			common = parts
+			continue
+		}
+		n := 0
+		for n < len(common) && n < len(parts) && common[n] == parts[n] {
+			n++
+		}
+		common = common[:n]
+	}
+	dir := strings.Join(common, string(filepath.Separator))
+	if dir == "" {
+		dir = string(filepath.Separator)
+	}
+	return dir, nil
+}
+
+// planOutputs assigns output names to inputs, keeping their paths relative
+// to the common directory, so that files with the same name in different
+// directories don't overwrite each other. Inputs, which are outputs of other
+// inputs from an earlier run, are skipped.
+func planOutputs(inputs []string) ([]job, error) {
+	base, err := commonDir(inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	isOutput := map[string]bool{}
+	for _, input := range inputs {
+		for _, ext := range []string{".gif", ".png"} {
+			isOutput[filepath.Clean(outputName(base, input, ext))] = true
+		}
+	}
+
+	var jobs []job
+	owner := map[string]string{}
+	for _, input := range inputs {
+		if isOutput[filepath.Clean(input)] {
+			continue
+		}
+		ext := outputExt(input)
+		output := outputName(base, input, ext)
+		if other, ok := owner[output]; ok {
+			return nil, fmt.Errorf("%v and %v would both be written to %v", other, input, output)
+		}
+		owner[output] = input
+		jobs = append(jobs, job{Input: input, Output: output, Ext: ext})
+	}
+	return jobs, nil
+}
+
+// job is a planned conversion of a file in batch mode.
+type job struct {
+	Input  string
+	Output string
+	// Ext is the extension of the format written to Output.
+	Ext string
+}
+
+type result struct {
+	Input  string
+	Output string
+	Err    error
+}
+
+func processFile(job job) result {
+	input, outname := job.Input, job.Output
+	data, err := ioutil.ReadFile(input)
+	if err != nil {
+		return result{Input: input, Err: err}
+	}
+
+	output, ext, err := twitterify(data)
+	if err != nil {
+		return result{Input: input, Err: err}
+	}
+	if ext != job.Ext {
+		// the input was replaced after planning
+		return result{Input: input, Err: fmt.Errorf("output is %s, but %v was planned", ext, outname)}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outname), 0755); err != nil {
+		return result{Input: input, Err: err}
+	}
+	return result{Input: input, Output: outname, Err: atomicfile.WriteFile(outname, output)}
+}
+
+// batch processes jobs in parallel, prints a summary and
+// returns the number of failed files.
+func batch(jobs []job) int {
+	results := make([]result, len(jobs))
+
+	workers := *parallel
+	if workers < 1 {
+		workers = 1
+	}
+
+	var wg sync.WaitGroup
+	next := make(chan int)
+	for k := 0; k < workers; k++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = processFile(jobs[i])
+			}
+		}()
+	}
+	for i := range jobs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "FAIL %v: %v\n", r.Input, r.Err)
+			continue
+		}
+		fmt.Printf("ok   %v -> %v\n", r.Input, r.Output)
+	}
+	fmt.Printf("%d succeeded, %d failed\n", len(results)-failed, failed)
+
+	return failed
+}
+
 func main() {
 	flag.Parse()
 
-	if flag.Arg(0) == "" || flag.Arg(1) == "" {
-		flag.Usage()
-		os.Exit(1)
+	switch {
+	case *bgspec != "":
+		var err error
+		background, err = parseBackground(*bgspec)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid background: %v\n", err)
+			os.Exit(1)
+		}
+	case *transparent:
+		background = Transparent{}
 	}
 
-	infile, err := os.Open(flag.Arg(0))
+	var err error
+	layout, err = parseLayout()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "%v\n", err)
+		fmt.Fprintf(os.Stderr, "invalid layout: %v\n", err)
 		os.Exit(1)
 	}
-	defer infile.Close()
 
-	outfile, err := os.Create(flag.Arg(1))
+	timing, err = parseTiming()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "%v\n", err)
+		fmt.Fprintf(os.Stderr, "invalid timing: %v\n", err)
 		os.Exit(1)
 	}
-	defer outfile.Close()
 
-	switch filepath.Ext(flag.Arg(0)) {
-	case ".gif":
-		err := handleGif(infile, outfile)
+	if *outdir != "" {
+		if flag.NArg() == 0 {
+			flag.Usage()
+			os.Exit(1)
+		}
+
+		inputs, err := expandInputs(flag.Args())
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "failed twitterifying gif: %v\n", err)
+			fmt.Fprintf(os.Stderr, "%v\n", err)
 			os.Exit(1)
 		}
-	case ".png":
-		err := handlePng(infile, outfile)
+		jobs, err := planOutputs(inputs)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "failed twitterifying png: %v\n", err)
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+
+		if batch(jobs) > 0 {
 			os.Exit(1)
 		}
+		return
+	}
+
+	if flag.Arg(0) == "" || flag.Arg(1) == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	data, err := ioutil.ReadFile(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	output, ext, err := twitterify(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed twitterifying %v: %v\n", flag.Arg(0), err)
+		os.Exit(1)
+	}
+	if !strings.EqualFold(filepath.Ext(flag.Arg(1)), ext) {
+		fmt.Fprintf(os.Stderr, "%v is written as %s, use a %s extension\n", flag.Arg(1), ext[1:], ext)
+		os.Exit(1)
+	}
+
+	if err := atomicfile.WriteFile(flag.Arg(1), output); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
 	}
 }
@@ -15,9 +15,11 @@ import (
 
 	"image"
 	"image/jpeg"
-	"image/png"
 
 	"golang.org/x/image/draw"
+
+	"github.com/egonelbre/gophers/pngopt"
+	"github.com/egonelbre/gophers/scale"
 )
 
 const (
@@ -119,7 +121,7 @@ func (collage *Collage) Draw(path string, m image.Image) {
 	})
 
 	inner := FitBoundsIntoFrame(m.Bounds(), frame)
-	draw.CatmullRom.Scale(collage.Image, inner, m, m.Bounds(), draw.Over, nil)
+	scale.Interpolator(m).Scale(collage.Image, inner, m, m.Bounds(), draw.Over, nil)
 
 	collage.X++
 	if collage.X >= collage.ColumnsPerRow {
@@ -207,7 +209,7 @@ func (thumbs *Thumbs) Downscale(actual, out string, m image.Image) image.Image {
 	})
 
 	rgba := image.NewRGBA(inner)
-	draw.CatmullRom.Scale(rgba, rgba.Bounds(), m, m.Bounds(), draw.Over, nil)
+	scale.Interpolator(m).Scale(rgba, rgba.Bounds(), m, m.Bounds(), draw.Over, nil)
 
 	return rgba
 }
@@ -464,5 +466,5 @@ func SavePNG(m image.Image, path string) error {
 	}
 	defer file.Close()
 
-	return png.Encode(file, m)
+	return pngopt.Encode(file, m)
 }
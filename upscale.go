@@ -0,0 +1,76 @@
+// upscale scales pixel art without blurring it.
+//
+//	go run upscale.go -algorithm scale3x icon/gopher-coin.png gopher-coin-3x.png
+//
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"os"
+	"strings"
+
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	"github.com/egonelbre/gophers/internal/atomicfile"
+	"github.com/egonelbre/gophers/pngopt"
+	"github.com/egonelbre/gophers/scale"
+)
+
+var (
+	algorithm = flag.String("algorithm", "nearest", "nearest, scale2x, scale3x, scale4x, epx or xbr")
+	factor    = flag.Int("factor", 2, "scale factor for nearest")
+)
+
+func upscale(m image.Image) (image.Image, error) {
+	switch strings.ToLower(*algorithm) {
+	case "nearest":
+		return scale.Nearest(m, *factor), nil
+	case "scale2x":
+		return scale.Scale2x(m), nil
+	case "scale3x":
+		return scale.Scale3x(m), nil
+	case "scale4x":
+		return scale.Scale2x(scale.Scale2x(m)), nil
+	case "epx":
+		return scale.EPX(m), nil
+	case "xbr":
+		return scale.XBR2x(m), nil
+	}
+	return nil, fmt.Errorf("unknown algorithm %q", *algorithm)
+}
+
+func main() {
+	flag.Parse()
+
+	if flag.Arg(0) == "" || flag.Arg(1) == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	infile, err := os.Open(flag.Arg(0))
+	check(err)
+	defer infile.Close()
+
+	source, _, err := image.Decode(infile)
+	check(err)
+
+	target, err := upscale(source)
+	check(err)
+
+	var output bytes.Buffer
+	check(pngopt.Encode(&output, target))
+	check(atomicfile.WriteFile(flag.Arg(1), output.Bytes()))
+}
+
+func check(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed: %v\n", err)
+		os.Exit(1)
+	}
+}